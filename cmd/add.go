@@ -13,6 +13,8 @@ import (
 const (
 	flagAdditional  = "additional"
 	flagGithub      = "github"
+	flagGitlab      = "gitlab"
+	flagGitlabURL   = "gitlab-url"
 	flagGoogle      = "google"
 	flagDownloadURL = "downloadURL"
 	flagVersion     = "version"
@@ -28,6 +30,14 @@ var addCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		gitlab, err := cmd.Flags().GetString(flagGitlab)
+		if err != nil {
+			return err
+		}
+		gitlabURL, err := cmd.Flags().GetString(flagGitlabURL)
+		if err != nil {
+			return err
+		}
 		google, err := cmd.Flags().GetString(flagGoogle)
 		if err != nil {
 			return err
@@ -37,8 +47,8 @@ var addCmd = &cobra.Command{
 			return err
 		}
 
-		if github == "" && downloadURL == "" && google == "" {
-			return fmt.Errorf("either %q, %q or %q must be defined", flagGithub, flagDownloadURL, flagGoogle)
+		if github == "" && gitlab == "" && downloadURL == "" && google == "" {
+			return fmt.Errorf("either %q, %q, %q or %q must be defined", flagGithub, flagGitlab, flagDownloadURL, flagGoogle)
 		}
 
 		cfg, err := cmd.Flags().GetString(flagConfig)
@@ -62,18 +72,21 @@ var addCmd = &cobra.Command{
 
 		if tool, ok := toolbox.Tools[args[0]]; ok {
 			log.Printf("🔄 Updating tool %s\n", args[0])
-			if google != "" {
-				tool.Github = ""
+			tool.Github = ""
+			tool.Gitlab = ""
+			tool.GitlabURL = ""
+			tool.Google = ""
+			tool.DownloadURL = ""
+			switch {
+			case google != "":
 				tool.Google = google
-				tool.DownloadURL = ""
-			} else if downloadURL != "" {
-				tool.Github = ""
-				tool.Google = ""
+			case downloadURL != "":
 				tool.DownloadURL = downloadURL
-			} else {
+			case gitlab != "":
+				tool.Gitlab = gitlab
+				tool.GitlabURL = gitlabURL
+			default:
 				tool.Github = github
-				tool.Google = ""
-				tool.DownloadURL = ""
 			}
 			if version != "" {
 				tool.Version = version
@@ -85,6 +98,9 @@ var addCmd = &cobra.Command{
 			log.Printf("↘️ Adding tool %s\n", args[0])
 			toolbox.Tools[args[0]] = &types.Tool{
 				Github:      github,
+				Gitlab:      gitlab,
+				GitlabURL:   gitlabURL,
+				Google:      google,
 				DownloadURL: downloadURL,
 				Version:     version,
 				Additional:  additional,
@@ -99,7 +115,9 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 	addConfigFlag(addCmd)
 	addCmd.Flags().String(flagGithub, "", "The tool's github repo")
-	addCmd.Flags().String(flagGoogle, "", "The tool's google URL")
+	addCmd.Flags().String(flagGitlab, "", "The tool's gitlab project")
+	addCmd.Flags().String(flagGitlabURL, "", "The base URL of a self-hosted gitlab instance (default https://gitlab.com)")
+	addCmd.Flags().String(flagGoogle, "", "The tool's google cloud storage bucket and prefix")
 	addCmd.Flags().String(flagDownloadURL, "", "The tool's download URL")
 	addCmd.Flags().String(flagVersion, "", "The tool's version or version URL")
 	addCmd.Flags().StringArray(flagAdditional, nil, "Additional tools to be fetched")
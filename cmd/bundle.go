@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/toolbox/pkg/fetcher"
+)
+
+const flagPlatforms = "platforms"
+
+// bundleCmd represents the bundle command.
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Bundle the tools of multiple platforms for an offline installation",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := cmd.Flags().GetString(flagConfig)
+		if err != nil {
+			return err
+		}
+		platforms, err := cmd.Flags().GetStringSlice(flagPlatforms)
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString(flagOutput)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return fetcher.Bundle(cfg, output, platforms...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	addConfigFlag(bundleCmd)
+	bundleCmd.Flags().StringSlice(flagPlatforms, nil,
+		"The platforms ('<os>/<arch>') to bundle the tools for (default current platform)")
+	bundleCmd.Flags().StringP(flagOutput, "o", "toolbox-bundle.tar.gz",
+		"The output of the bundle. A tarball if ending with '.tar.gz' or '.tgz', otherwise a directory")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/fetcher"
+)
+
+// cacheCmd represents the cache command.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the download cache",
+}
+
+// cacheListCmd represents the cache list command.
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached downloads",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		c, err := cache.Default()
+		if err != nil {
+			return err
+		}
+		entries, err := c.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "URL\tVERSION\tSIZE\tLAST USED")
+		for _, e := range entries {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.URL, e.Version, fetcher.FormatBytes(e.Size), e.LastUsed.Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+// cacheCleanCmd represents the cache clean command.
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Delete all cached downloads",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		c, err := cache.Default()
+		if err != nil {
+			return err
+		}
+		if err := c.Clean(); err != nil {
+			return err
+		}
+		log.Printf("🧹 Cleaned download cache %s", c.Dir())
+		return nil
+	},
+}
+
+// cacheSizeCmd represents the cache size command.
+var cacheSizeCmd = &cobra.Command{
+	Use:   "size",
+	Short: "Print the size of the download cache",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		c, err := cache.Default()
+		if err != nil {
+			return err
+		}
+		size, err := c.Size()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%s\n", fetcher.FormatBytes(size), c.Dir())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd, cacheCleanCmd, cacheSizeCmd)
+}
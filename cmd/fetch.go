@@ -6,7 +6,18 @@ import (
 	"github.com/bakito/toolbox/pkg/fetcher"
 )
 
-const flagConfig = "config"
+const (
+	flagConfig   = "config"
+	flagLocked   = "locked"
+	flagParallel = "parallel"
+	flagDryRun   = "dry-run"
+	flagOS       = "os"
+	flagArch     = "arch"
+	flagTarget   = "target"
+	flagFrom     = "from"
+	flagNoCache  = "no-cache"
+	flagRefresh  = "refresh"
+)
 
 // fetchCmd represents the fetch command.
 var fetchCmd = &cobra.Command{
@@ -17,13 +28,73 @@ var fetchCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return fetcher.New().Fetch(cfg, args...)
+		locked, err := cmd.Flags().GetBool(flagLocked)
+		if err != nil {
+			return err
+		}
+		parallel, err := cmd.Flags().GetInt(flagParallel)
+		if err != nil {
+			return err
+		}
+		dryRun, err := cmd.Flags().GetBool(flagDryRun)
+		if err != nil {
+			return err
+		}
+		goos, err := cmd.Flags().GetString(flagOS)
+		if err != nil {
+			return err
+		}
+		goarch, err := cmd.Flags().GetString(flagArch)
+		if err != nil {
+			return err
+		}
+		target, err := cmd.Flags().GetString(flagTarget)
+		if err != nil {
+			return err
+		}
+		from, err := cmd.Flags().GetString(flagFrom)
+		if err != nil {
+			return err
+		}
+		noCache, err := cmd.Flags().GetBool(flagNoCache)
+		if err != nil {
+			return err
+		}
+		refresh, err := cmd.Flags().GetBool(flagRefresh)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		return fetcher.New(fetcher.Options{
+			Locked:   locked,
+			Parallel: parallel,
+			DryRun:   dryRun,
+			OS:       goos,
+			Arch:     goarch,
+			Target:   target,
+			From:     from,
+			NoCache:  noCache,
+			Refresh:  refresh,
+		}).Fetch(cfg, args...)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(fetchCmd)
 	addConfigFlag(fetchCmd)
+	fetchCmd.Flags().Bool(flagLocked, false, "Install the tools exactly as defined in the lock file, "+
+		"without resolving the latest versions")
+	fetchCmd.Flags().IntP(flagParallel, "p", 1, "The number of tools fetched in parallel")
+	fetchCmd.Flags().Bool(flagDryRun, false, "Report which tools would be installed or upgraded without downloading "+
+		"anything. Exits with a non-zero code if updates are available or tools were skipped")
+	fetchCmd.Flags().String(flagOS, "", "The operating system to fetch the tools for (default current OS)")
+	fetchCmd.Flags().String(flagArch, "", "The architecture to fetch the tools for (default current architecture)")
+	fetchCmd.Flags().String(flagTarget, "", "The target directory overriding the target of the config file")
+	fetchCmd.Flags().String(flagFrom, "", "Install the tools from a bundle (directory or tarball) "+
+		"created with 'toolbox bundle', without any network calls")
+	fetchCmd.MarkFlagsMutuallyExclusive(flagFrom, flagLocked)
+	fetchCmd.Flags().Bool(flagNoCache, false, "Do not use the download cache")
+	fetchCmd.Flags().Bool(flagRefresh, false, "Do not use cached github API responses")
 }
 
 func addConfigFlag(cmd *cobra.Command) {
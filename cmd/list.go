@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/fetcher"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const (
+	flagOutput = "output"
+	flagLatest = "latest"
+
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// listCmd represents the list command.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured and installed tools",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := cmd.Flags().GetString(flagConfig)
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString(flagOutput)
+		if err != nil {
+			return err
+		}
+		latest, err := cmd.Flags().GetBool(flagLatest)
+		if err != nil {
+			return err
+		}
+
+		status, err := fetcher.List(resty.New(), cfg, latest)
+		if err != nil {
+			return err
+		}
+
+		switch output {
+		case outputJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(status)
+		case outputYAML:
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			return enc.Encode(status)
+		case "":
+			return printStatusTable(status, latest)
+		default:
+			return fmt.Errorf("unsupported output format %q", output)
+		}
+	},
+}
+
+func printStatusTable(status []types.ToolStatus, latest bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if latest {
+		_, _ = fmt.Fprintln(w, "TOOL\tSOURCE\tPINNED\tINSTALLED\tLATEST\tEXISTS")
+	} else {
+		_, _ = fmt.Fprintln(w, "TOOL\tSOURCE\tPINNED\tINSTALLED\tEXISTS")
+	}
+	for _, ts := range status {
+		if latest {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", ts.Name, ts.Source, ts.Pinned, ts.Installed, ts.Latest, ts.Exists)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", ts.Name, ts.Source, ts.Pinned, ts.Installed, ts.Exists)
+		}
+	}
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	addConfigFlag(listCmd)
+	listCmd.Flags().StringP(flagOutput, "o", "", "The output format (json|yaml), default is a table")
+	listCmd.Flags().Bool(flagLatest, false, "Resolve the latest available version of each tool")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/toolbox/pkg/fetcher"
+)
+
+const flagYes = "yes"
+
+// pruneCmd represents the prune command.
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete installed tools that are not configured anymore",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := cmd.Flags().GetString(flagConfig)
+		if err != nil {
+			return err
+		}
+		yes, err := cmd.Flags().GetBool(flagYes)
+		if err != nil {
+			return err
+		}
+		return fetcher.Prune(cfg, func(orphans []string) bool {
+			fmt.Println("Orphaned tools:")
+			for _, o := range orphans {
+				fmt.Printf("  - %s\n", o)
+			}
+			if yes {
+				return true
+			}
+			fmt.Print("Delete these tools? [y/N] ")
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+	addConfigFlag(pruneCmd)
+	pruneCmd.Flags().BoolP(flagYes, "y", false, "Delete the orphaned tools without confirmation")
+}
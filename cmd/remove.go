@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/toolbox/pkg/fetcher"
+)
+
+const flagKeepBinary = "keep-binary"
+
+// removeCmd represents the remove command.
+var removeCmd = &cobra.Command{
+	Use:   "remove <tool-name>",
+	Short: "Remove a tool from the config",
+	Args:  cobra.MatchAll(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := cmd.Flags().GetString(flagConfig)
+		if err != nil {
+			return err
+		}
+		keepBinary, err := cmd.Flags().GetBool(flagKeepBinary)
+		if err != nil {
+			return err
+		}
+		return fetcher.Remove(cfg, args[0], keepBinary)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+	addConfigFlag(removeCmd)
+	removeCmd.Flags().Bool(flagKeepBinary, false, "Keep the tool's binaries in the target dir")
+}
@@ -3,6 +3,7 @@ package arch
 
 import (
 	"debug/elf"
+	"debug/macho"
 	"debug/pe"
 	"fmt"
 	"os"
@@ -10,22 +11,26 @@ import (
 )
 
 func DoesBinaryMatchCurrentOSArch(fileName string) (bool, error) {
-	currentOS := runtime.GOOS
-	currentArch := runtime.GOARCH
+	return DoesBinaryMatchOSArch(fileName, runtime.GOOS, runtime.GOARCH)
+}
 
+// DoesBinaryMatchOSArch checks if the binary is built for the given os and arch.
+func DoesBinaryMatchOSArch(fileName, goos, goarch string) (bool, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
 		return false, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
 
-	switch currentOS {
+	switch goos {
 	case "windows":
-		return checkPEFileArch(file, currentArch)
+		return checkPEFileArch(file, goarch)
 	case "linux":
-		return checkELFFileArch(file, currentArch)
+		return checkELFFileArch(file, goarch)
+	case "darwin":
+		return checkMachOFileArch(file, goarch)
 	default:
-		return false, fmt.Errorf("unsupported OS: %s", currentOS)
+		return false, fmt.Errorf("unsupported OS: %s", goos)
 	}
 }
 
@@ -82,3 +87,35 @@ func checkELFFileArch(file *os.File, currentArch string) (bool, error) {
 
 	return arch == currentArch, nil
 }
+
+func checkMachOFileArch(file *os.File, currentArch string) (bool, error) {
+	machoFile, err := macho.NewFile(file)
+	if err == nil {
+		defer machoFile.Close()
+		return machOArch(machoFile.Cpu) == currentArch, nil
+	}
+
+	// universal binaries contain one binary per architecture
+	fatFile, fatErr := macho.NewFatFile(file)
+	if fatErr != nil {
+		return false, fmt.Errorf("error opening Mach-O file: %w", err)
+	}
+	defer fatFile.Close()
+	for _, a := range fatFile.Arches {
+		if machOArch(a.Cpu) == currentArch {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func machOArch(cpu macho.Cpu) string {
+	switch cpu {
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm64:
+		return "arm64"
+	default:
+		return cpu.String()
+	}
+}
@@ -0,0 +1,77 @@
+package arch
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoesBinaryMatchOSArchMachO(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		arch    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "thin amd64", content: machO(macho.CpuAmd64), arch: "amd64", want: true},
+		{name: "thin arm64", content: machO(macho.CpuArm64), arch: "arm64", want: true},
+		{name: "thin amd64 mismatch", content: machO(macho.CpuAmd64), arch: "arm64", want: false},
+		{name: "thin arm64 mismatch", content: machO(macho.CpuArm64), arch: "amd64", want: false},
+		{name: "fat amd64", content: fatMachO(macho.CpuAmd64, macho.CpuArm64), arch: "amd64", want: true},
+		{name: "fat arm64", content: fatMachO(macho.CpuAmd64, macho.CpuArm64), arch: "arm64", want: true},
+		{name: "fat mismatch", content: fatMachO(macho.CpuAmd64), arch: "arm64", want: false},
+		{name: "no Mach-O file", content: []byte("#!/bin/sh\necho foo\n"), arch: "amd64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tool")
+			if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DoesBinaryMatchOSArch(path, "darwin", tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DoesBinaryMatchOSArch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DoesBinaryMatchOSArch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// machO returns the header of a 64-bit Mach-O executable without load commands.
+func machO(cpu macho.Cpu) []byte {
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64,
+		Cpu:   cpu,
+		Type:  macho.TypeExec,
+	})
+	// reserved field of the 64-bit header
+	_ = binary.Write(&b, binary.LittleEndian, uint32(0))
+	return b.Bytes()
+}
+
+// fatMachO returns a universal binary containing a Mach-O executable per cpu.
+func fatMachO(cpus ...macho.Cpu) []byte {
+	const align = 12
+	var b bytes.Buffer
+	_ = binary.Write(&b, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(cpus))})
+	for i, cpu := range cpus {
+		_ = binary.Write(&b, binary.BigEndian, macho.FatArchHeader{
+			Cpu:    cpu,
+			Offset: uint32(i+1) << align,
+			Size:   uint32(len(machO(cpu))),
+			Align:  align,
+		})
+	}
+	for i, cpu := range cpus {
+		b.Write(make([]byte, (i+1)<<align-b.Len()))
+		b.Write(machO(cpu))
+	}
+	return b.Bytes()
+}
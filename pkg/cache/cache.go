@@ -0,0 +1,315 @@
+// Package cache provides a persistent download cache shared across projects
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/quietly"
+)
+
+const (
+	// EnvCacheDir the env variable to override the cache dir.
+	EnvCacheDir = "TOOLBOX_CACHE_DIR"
+
+	// DefaultMaxAge the default age of unused entries before they are evicted.
+	DefaultMaxAge = 30 * 24 * time.Hour
+	// DefaultMaxSize the default maximum size of the cache in bytes.
+	DefaultMaxSize = 1 << 30
+	// DefaultResponseTTL the default duration cached responses are used without revalidation.
+	DefaultResponseTTL = 10 * time.Minute
+
+	blobsDir   = "blobs"
+	entriesDir = "entries"
+)
+
+// Cache a content addressed download cache.
+// Downloaded files are stored once per sha256, entries map the download URL and version to the stored file.
+type Cache struct {
+	dir string
+	now func() time.Time
+}
+
+// Entry a cached download.
+type Entry struct {
+	URL      string    `yaml:"url"`
+	Version  string    `yaml:"version,omitempty"`
+	Sha256   string    `yaml:"sha256"`
+	Size     int64     `yaml:"size"`
+	Created  time.Time `yaml:"created"`
+	LastUsed time.Time `yaml:"lastUsed"`
+}
+
+// New returns a cache stored in the given dir.
+func New(dir string) *Cache {
+	return &Cache{dir: dir, now: time.Now}
+}
+
+// Default returns the cache in the env variable 'TOOLBOX_CACHE_DIR' or in '$XDG_CACHE_HOME/toolbox'
+// (the user cache dir of the os).
+func Default() (*Cache, error) {
+	if dir := os.Getenv(EnvCacheDir); dir != "" {
+		return New(dir), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return New(filepath.Join(dir, "toolbox")), nil
+}
+
+// Dir returns the dir of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns the path of the cached file of the given URL and version.
+// The version distinguishes downloads of URLs not containing the version.
+func (c *Cache) Get(url, version string) (string, bool) {
+	e, err := c.readEntry(entryFile(c.dir, url, version))
+	if err != nil {
+		return "", false
+	}
+	path := c.blobPath(e.Sha256)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	e.LastUsed = c.now()
+	_ = c.writeEntry(e)
+	return path, true
+}
+
+// Put stores the file as download of the given URL and version.
+func (c *Cache) Put(url, version, file string) error {
+	sum, size, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+
+	blob := c.blobPath(sum)
+	if _, err := os.Stat(blob); errors.Is(err, os.ErrNotExist) {
+		if err := copyFile(file, blob); err != nil {
+			return err
+		}
+	}
+
+	now := c.now()
+	return c.writeEntry(&Entry{URL: url, Version: version, Sha256: sum, Size: size, Created: now, LastUsed: now})
+}
+
+// List returns all entries of the cache, the most recently used first.
+func (c *Cache) List() ([]*Entry, error) {
+	files, err := os.ReadDir(filepath.Join(c.dir, entriesDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []*Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		e, err := c.readEntry(filepath.Join(c.dir, entriesDir, f.Name()))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int {
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+	return entries, nil
+}
+
+// Size returns the size of all cached files.
+func (c *Cache) Size() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(c.dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Clean deletes all cached files.
+func (c *Cache) Clean() error {
+	return os.RemoveAll(c.dir)
+}
+
+// Evict deletes the entries not used within maxAge and the least recently used entries
+// until the size of the cache is below maxSize. A value of 0 disables the respective limit.
+// Cached responses older than maxAge are deleted as well. The evicted entries are returned.
+func (c *Cache) Evict(maxAge time.Duration, maxSize int64) ([]*Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var kept, evicted []*Entry
+	for _, e := range entries {
+		if maxAge > 0 && c.now().Sub(e.LastUsed) > maxAge {
+			evicted = append(evicted, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+
+	if maxSize > 0 {
+		var size int64
+		blobs := make(map[string]bool)
+		var within []*Entry
+		// entries are sorted by last usage, the least recently used are evicted first
+		for _, e := range kept {
+			var add int64
+			if !blobs[e.Sha256] {
+				add = e.Size
+			}
+			// evicted entries do not count towards the size, their blob is removed unless a kept entry shares it
+			if size+add > maxSize {
+				evicted = append(evicted, e)
+				continue
+			}
+			size += add
+			blobs[e.Sha256] = true
+			within = append(within, e)
+		}
+		kept = within
+	}
+
+	for _, e := range evicted {
+		if err := os.Remove(entryFile(c.dir, e.URL, e.Version)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	for _, dir := range []string{responsesDir, partialDir} {
+		if err := c.evictFiles(dir, maxAge); err != nil {
+			return nil, err
+		}
+	}
+	return evicted, c.removeUnreferencedBlobs(kept)
+}
+
+func (c *Cache) removeUnreferencedBlobs(entries []*Entry) error {
+	referenced := make(map[string]bool)
+	for _, e := range entries {
+		referenced[e.Sha256] = true
+	}
+	files, err := os.ReadDir(filepath.Join(c.dir, blobsDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		// temporary files are written by running downloads
+		if !referenced[f.Name()] && !strings.HasPrefix(f.Name(), ".tmp-") {
+			if err := os.Remove(filepath.Join(c.dir, blobsDir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cache) blobPath(sum string) string {
+	return filepath.Join(c.dir, blobsDir, sum)
+}
+
+func (*Cache) readEntry(path string) (*Entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{}
+	if err := yaml.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (c *Cache) writeEntry(e *Entry) error {
+	path := entryFile(c.dir, e.URL, e.Version)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+func entryFile(dir, url, version string) string {
+	key := url
+	if version != "" {
+		key += "#" + version
+	}
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, entriesDir, hex.EncodeToString(h[:])+".yaml")
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer quietly.Close(file)
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// copyFile copies the file via a temporary file, to never expose partially written files.
+func copyFile(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(src)
+
+	tmp, err := os.CreateTemp(filepath.Dir(to), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		quietly.Close(tmp)
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), to)
+}
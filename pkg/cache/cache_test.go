@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetPut(t *testing.T) {
+	c := New(t.TempDir())
+	if _, ok := c.Get("https://example.com/a.tar.gz", "v1.0.0"); ok {
+		t.Fatal("Expected empty cache")
+	}
+
+	file := writeFile(t, "content")
+	if err := c.Put("https://example.com/a.tar.gz", "v1.0.0", file); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := c.Put("https://mirror.example.com/a.tar.gz", "v1.0.0", file); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	path, ok := c.Get("https://example.com/a.tar.gz", "v1.0.0")
+	if !ok {
+		t.Fatal("Expected cached file")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "content" {
+		t.Errorf("Expected: content, but got: %s", b)
+	}
+
+	blobs, err := os.ReadDir(filepath.Join(c.Dir(), blobsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 {
+		t.Errorf("Expected identical content to be stored once, but got %d files", len(blobs))
+	}
+
+	// urls without version serve different content per version
+	if _, ok := c.Get("https://example.com/a.tar.gz", "v2.0.0"); ok {
+		t.Error("Expected no cached file for another version")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		maxSize     int64
+		wantEvicted []string
+		wantKept    []string
+	}{
+		{
+			name:     "No limits",
+			wantKept: []string{"new", "mid", "old"},
+		},
+		{
+			name:        "Max age",
+			maxAge:      10 * 24 * time.Hour,
+			wantEvicted: []string{"old"},
+			wantKept:    []string{"new", "mid"},
+		},
+		{
+			name:        "Max size",
+			maxSize:     6,
+			wantEvicted: []string{"mid", "old"},
+			wantKept:    []string{"new"},
+		},
+		{
+			name:        "Max size does not count evicted entries",
+			maxSize:     10,
+			wantEvicted: []string{"mid"},
+			wantKept:    []string{"new", "old"},
+		},
+		{
+			name:        "Max size evicts least recently used",
+			maxSize:     11,
+			wantEvicted: []string{"old"},
+			wantKept:    []string{"new", "mid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(t.TempDir())
+			for name, entry := range map[string]struct {
+				content string
+				age     time.Duration
+			}{
+				"new": {content: "12345", age: 0},
+				"mid": {content: "123456", age: 5 * 24 * time.Hour},
+				"old": {content: "12", age: 20 * 24 * time.Hour},
+			} {
+				c.now = func() time.Time { return now.Add(-entry.age) }
+				if err := c.Put(name, "", writeFile(t, entry.content)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.now = func() time.Time { return now }
+
+			evicted, err := c.Evict(tt.maxAge, tt.maxSize)
+			if err != nil {
+				t.Fatalf("Evict() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantEvicted, urls(evicted)); diff != "" {
+				t.Errorf("Evict() evicted mismatch (-want +got):\n%s", diff)
+			}
+			kept, err := c.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantKept, urls(kept)); diff != "" {
+				t.Errorf("Evict() kept mismatch (-want +got):\n%s", diff)
+			}
+			blobs, err := os.ReadDir(filepath.Join(c.Dir(), blobsDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(blobs) != len(tt.wantKept) {
+				t.Errorf("Expected %d blobs, but got %d", len(tt.wantKept), len(blobs))
+			}
+		})
+	}
+}
+
+func TestSizeAndClean(t *testing.T) {
+	c := New(filepath.Join(t.TempDir(), "cache"))
+	size, err := c.Size()
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	if size != 0 {
+		t.Errorf("Expected empty cache, but got size %d", size)
+	}
+
+	if err := c.Put("https://example.com/a", "v1.0.0", writeFile(t, "content")); err != nil {
+		t.Fatal(err)
+	}
+	if size, _ = c.Size(); size <= int64(len("content")) {
+		t.Errorf("Expected size to include the cached file, but got %d", size)
+	}
+
+	if err := c.Clean(); err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+	if _, ok := c.Get("https://example.com/a", "v1.0.0"); ok {
+		t.Error("Expected cache to be empty after clean")
+	}
+}
+
+func TestEvictSharedBlobs(t *testing.T) {
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	c := New(t.TempDir())
+	for i, entry := range []struct {
+		url     string
+		content string
+	}{
+		{url: "new", content: "123456"},
+		{url: "mid", content: "1234"},
+		{url: "old", content: "123456"},
+		{url: "oldest", content: "12"},
+	} {
+		c.now = func() time.Time { return now.Add(-time.Duration(i) * time.Hour) }
+		if err := c.Put(entry.url, "", writeFile(t, entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.now = func() time.Time { return now }
+
+	// 'old' shares the blob of 'new' and does not add to the size
+	evicted, err := c.Evict(0, 8)
+	if err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"mid"}, urls(evicted)); diff != "" {
+		t.Errorf("Evict() evicted mismatch (-want +got):\n%s", diff)
+	}
+	kept, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"new", "old", "oldest"}, urls(kept)); diff != "" {
+		t.Errorf("Evict() kept mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func urls(entries []*Entry) []string {
+	var u []string
+	for _, e := range entries {
+		u = append(u, e.URL)
+	}
+	return u
+}
+
+func TestEvictPartialFiles(t *testing.T) {
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	c := New(t.TempDir())
+	c.now = func() time.Time { return now }
+
+	stale, unlockStale, err := c.PartialFile("https://example.com/stale.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockStale()
+	recent, unlockRecent, err := c.PartialFile("https://example.com/recent.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlockRecent()
+	for path, age := range map[string]time.Duration{stale: 20 * 24 * time.Hour, recent: time.Hour} {
+		if err := os.WriteFile(path, []byte("partial"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Evict(10*24*time.Hour, 0); err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected stale partial download to be evicted, but got: %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected recent partial download to be kept, but got: %v", err)
+	}
+}
+
+func TestPartialFileLock(t *testing.T) {
+	c := New(t.TempDir())
+	url := "https://example.com/tool.tar.gz"
+
+	partial, unlock, err := c.PartialFile(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.PartialFile(url); !errors.Is(err, ErrPartialLocked) {
+		t.Fatalf("PartialFile() of a locked partial error = %v, want %v", err, ErrPartialLocked)
+	}
+	other, unlockOther, err := c.PartialFile("https://example.com/other.tar.gz")
+	if err != nil {
+		t.Fatalf("PartialFile() of another url error = %v", err)
+	}
+	unlockOther()
+	if other == partial {
+		t.Errorf("Expected different partial files per url, but got: %s", other)
+	}
+
+	unlock()
+	again, unlock, err := c.PartialFile(url)
+	if err != nil {
+		t.Fatalf("PartialFile() of an unlocked partial error = %v", err)
+	}
+	unlock()
+	if again != partial {
+		t.Errorf("Expected the same partial file, but got: %s, want %s", again, partial)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+// errLocked the error of lockFile, if the lock is held by another open file.
+var errLocked = syscall.EWOULDBLOCK
+
+// lockFile takes an exclusive lock on the file without waiting.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
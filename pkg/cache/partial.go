@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	partialDir = "partial"
+	// validatorExtension the extension of the file storing the validator of a partial download.
+	validatorExtension = ".validator"
+	// lockExtension the extension of the file locking a partial download.
+	lockExtension = ".lock"
+)
+
+// ErrPartialLocked the partial download is locked by another running download of the url.
+var ErrPartialLocked = errors.New("partial download is locked by another download")
+
+// PartialFile returns the path of the partial download of the url, locked exclusively by the caller.
+// Interrupted downloads are kept there to be resumed by the next attempt. The returned func releases the lock.
+// ErrPartialLocked is returned if another download of the url, in this or another process, holds the lock.
+func (c *Cache) PartialFile(url string) (string, func(), error) {
+	dir := filepath.Join(c.dir, partialDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+	h := sha256.Sum256([]byte(url))
+	partial := filepath.Join(dir, hex.EncodeToString(h[:]))
+
+	lock, err := os.OpenFile(partial+lockExtension, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := lockFile(lock); err != nil {
+		_ = lock.Close()
+		if errors.Is(err, errLocked) {
+			return "", nil, ErrPartialLocked
+		}
+		return "", nil, err
+	}
+	// the lock file is evicted like the partial download, if not used
+	now := c.now()
+	_ = os.Chtimes(lock.Name(), now, now)
+	return partial, func() {
+		_ = unlockFile(lock)
+		_ = lock.Close()
+	}, nil
+}
+
+// PartialValidator returns the validator (ETag or Last-Modified) of the response the partial download was
+// started with, or an empty string if unknown.
+func PartialValidator(partial string) string {
+	b, err := os.ReadFile(partial + validatorExtension)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// SetPartialValidator stores the validator of the response the partial download is started with.
+// An empty validator deletes a stored one.
+func SetPartialValidator(partial, validator string) error {
+	if validator == "" {
+		return removeFile(partial + validatorExtension)
+	}
+	return os.WriteFile(partial+validatorExtension, []byte(validator), 0o600)
+}
+
+// RemovePartial deletes the partial download and its validator.
+func RemovePartial(partial string) error {
+	return errors.Join(removeFile(partial), removeFile(partial+validatorExtension))
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
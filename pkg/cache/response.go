@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const responsesDir = "responses"
+
+// Response a cached http response.
+type Response struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Stored       time.Time `json:"stored"`
+	Body         []byte    `json:"body"`
+}
+
+// Fresh returns true if the response was stored within the ttl.
+func (r *Response) Fresh(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(r.Stored) < ttl
+}
+
+// GetResponse returns the cached response of the given key.
+func (c *Cache) GetResponse(key string) (*Response, bool) {
+	b, err := os.ReadFile(c.responseFile(key))
+	if err != nil {
+		return nil, false
+	}
+	r := &Response{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// PutResponse stores the response with the given key.
+func (c *Cache) PutResponse(key string, r *Response) error {
+	path := c.responseFile(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Now returns the current time of the cache.
+func (c *Cache) Now() time.Time {
+	return c.now()
+}
+
+// ResponseKey returns the key of a response of the url.
+// Responses depend on the credentials, therefore different credentials result in different keys.
+func ResponseKey(url, credentials string) string {
+	h := sha256.Sum256([]byte(url + "\n" + credentials))
+	return hex.EncodeToString(h[:])
+}
+
+func (c *Cache) responseFile(key string) string {
+	return filepath.Join(c.dir, responsesDir, key+".json")
+}
+
+// evictFiles deletes the files in dir modified before maxAge.
+func (c *Cache) evictFiles(dir string, maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	files, err := os.ReadDir(filepath.Join(c.dir, dir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if c.now().Sub(info.ModTime()) > maxAge {
+			if err := os.Remove(filepath.Join(c.dir, dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
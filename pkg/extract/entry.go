@@ -0,0 +1,382 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bakito/toolbox/pkg/quietly"
+)
+
+// maxLinkLength the max length of a symlink target stored as content of a zip or 7z entry.
+const maxLinkLength = 4096
+
+// ErrUnsafeEntry an archive entry was rejected, as it is invalid or would be written outside the target dir.
+var ErrUnsafeEntry = errors.New("unsafe archive entry")
+
+// EntryType the type of an extracted archive entry.
+type EntryType string
+
+const (
+	TypeFile     EntryType = "file"
+	TypeDir      EntryType = "dir"
+	TypeSymlink  EntryType = "symlink"
+	TypeHardlink EntryType = "hardlink"
+)
+
+// Entry an extracted archive entry.
+type Entry struct {
+	// Path the path of the entry relative to the target dir.
+	Path string
+	Type EntryType
+	Mode os.FileMode
+	// Link the target of a symlink or hardlink.
+	Link string
+}
+
+// Result the result of the extraction of a file.
+type Result struct {
+	// Archive true if the file is an archive and was extracted.
+	Archive bool
+	// Entries the extracted entries.
+	Entries []Entry
+	// Contents the names of all entries of the archive, including the entries not matched by the filter.
+	// Dirs end with a '/'.
+	Contents []string
+}
+
+// Count returns the number of extracted entries of the given type.
+func (r *Result) Count(t EntryType) int {
+	count := 0
+	for _, e := range r.Entries {
+		if e.Type == t {
+			count++
+		}
+	}
+	return count
+}
+
+// extractor writes archive entries into the target dir.
+// Entries are never written outside the target dir, neither by their path nor through links.
+type extractor struct {
+	target string
+	result *Result
+	sizes  *sizeTracker
+	// filter extracts only entries matching the filter, all entries are extracted if nil
+	filter func(name string) bool
+	// linkPass true when extracting the link targets not matched by the filter
+	linkPass bool
+	// pending the names of entries to extract in the link pass
+	pending map[string]bool
+}
+
+func newExtractor(target string, opts Options) (*extractor, error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+	return &extractor{
+		target:  abs,
+		result:  &Result{Archive: true},
+		sizes:   &sizeTracker{limits: opts.Limits.withDefaults()},
+		filter:  opts.Filter,
+		pending: make(map[string]bool),
+	}, nil
+}
+
+// skip records the entry in the contents of the archive and returns true if it is not matched by the filter.
+func (e *extractor) skip(name string, dir bool) (bool, error) {
+	name = entryName(name)
+	if !e.linkPass {
+		if err := e.sizes.entry(name); err != nil {
+			return false, err
+		}
+		if dir {
+			e.result.Contents = append(e.result.Contents, name+"/")
+		} else {
+			e.result.Contents = append(e.result.Contents, name)
+		}
+	}
+	return e.filter != nil && !e.filter(name), nil
+}
+
+// file writes a regular file with the permissions of the mode.
+func (e *extractor) file(name string, mode os.FileMode, r io.Reader) error {
+	if skip, err := e.skip(name, false); skip || err != nil {
+		return err
+	}
+	path, rel, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	perm := mode.Perm() | 0o600
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(out)
+	if err := e.sizes.copy(name, out, r); err != nil {
+		quietly.Close(out)
+		_ = os.Remove(path)
+		return err
+	}
+	e.add(Entry{Path: rel, Type: TypeFile, Mode: perm})
+	return nil
+}
+
+// dir creates a directory, the owner keeps full access to be able to extract the content.
+func (e *extractor) dir(name string, mode os.FileMode) error {
+	if skip, err := e.skip(name, true); skip || err != nil {
+		return err
+	}
+	path, rel, err := e.safePath(name)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	if err := e.checkParents(rel); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(path); err == nil && !fi.IsDir() {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	perm := mode.Perm() | 0o700
+	if err := os.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		return err
+	}
+	e.add(Entry{Path: rel, Type: TypeDir, Mode: perm})
+	return nil
+}
+
+// symlink creates a symlink. Only relative links resolving within the target dir are allowed.
+func (e *extractor) symlink(name, link string) error {
+	if skip, err := e.skip(name, false); skip || err != nil {
+		return err
+	}
+	path, rel, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	if err := e.checkLink(rel, link); err != nil {
+		return err
+	}
+	if err := os.Symlink(link, path); err != nil {
+		// symlinks might not be supported (e.g. on windows without privileges), copy the linked file instead
+		source := filepath.Join(filepath.Dir(path), filepath.FromSlash(link))
+		if fi, statErr := os.Stat(source); statErr != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		if err := copyFile(source, path); err != nil {
+			return err
+		}
+		e.add(Entry{Path: rel, Type: TypeFile, Link: link})
+		return nil
+	}
+	e.add(Entry{Path: rel, Type: TypeSymlink, Link: link})
+	return nil
+}
+
+// hardlink creates a hardlink to a previously extracted regular file.
+// If the file was skipped by the filter, the file and the hardlink are extracted in the link pass.
+func (e *extractor) hardlink(name, linkName string) error {
+	if skip, err := e.skip(name, false); skip || err != nil {
+		return err
+	}
+	source, sourceRel, err := e.safePath(linkName)
+	if err != nil {
+		return err
+	}
+	if err := e.checkParents(sourceRel); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(source)
+	sourceSkipped := e.filter != nil && !e.linkPass && slices.Contains(e.result.Contents, entryName(linkName))
+	if errors.Is(err, os.ErrNotExist) && sourceSkipped {
+		e.pending[entryName(linkName)] = true
+		e.pending[entryName(name)] = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("hardlink %s: %w", name, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%w: hardlink %s: %s is not a regular file", ErrUnsafeEntry, name, linkName)
+	}
+	path, rel, err := e.prepare(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Link(source, path); err != nil {
+		if err := copyFile(source, path); err != nil {
+			return err
+		}
+	}
+	e.add(Entry{Path: rel, Type: TypeHardlink, Mode: fi.Mode().Perm(), Link: sourceRel})
+	return nil
+}
+
+func (e *extractor) add(entry Entry) {
+	e.result.Entries = append(e.result.Entries, entry)
+}
+
+// missingLinkTargets returns the names of the link targets that are part of the archive but not extracted,
+// as they are not matched by the filter.
+func (e *extractor) missingLinkTargets() map[string]bool {
+	extracted := make(map[string]bool)
+	for _, entry := range e.result.Entries {
+		extracted[filepath.ToSlash(entry.Path)] = true
+	}
+	contents := make(map[string]bool)
+	for _, c := range e.result.Contents {
+		contents[strings.TrimSuffix(c, "/")] = true
+	}
+	missing := make(map[string]bool)
+	for name := range e.pending {
+		if !extracted[name] {
+			missing[name] = true
+		}
+	}
+	for _, entry := range e.result.Entries {
+		if entry.Type != TypeSymlink {
+			continue
+		}
+		target := path.Join(path.Dir(filepath.ToSlash(entry.Path)), strings.ReplaceAll(entry.Link, "\\", "/"))
+		if !extracted[target] && (contents[target] || slices.ContainsFunc(e.result.Contents, func(c string) bool {
+			return strings.HasPrefix(c, target+"/")
+		})) {
+			missing[target] = true
+		}
+	}
+	return missing
+}
+
+// entryName returns the clean slash separated name of an archive entry, as passed to the filter.
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// prepare returns the path of a new entry, after creating the parent dirs and removing an existing file.
+// Existing files are removed, to never write through a previously extracted symlink.
+func (e *extractor) prepare(name string) (path, rel string, err error) {
+	path, rel, err = e.safePath(name)
+	if err != nil {
+		return "", "", err
+	}
+	if rel == "." {
+		return "", "", fmt.Errorf("%w: invalid entry name %q", ErrUnsafeEntry, name)
+	}
+	if err := e.checkParents(rel); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", "", err
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.IsDir() {
+			return "", "", fmt.Errorf("%w: entry %s conflicts with an existing dir", ErrUnsafeEntry, name)
+		}
+		if err := os.Remove(path); err != nil {
+			return "", "", err
+		}
+	}
+	return path, rel, nil
+}
+
+// safePath returns the path of the entry in the target dir and the path relative to the target dir.
+func (e *extractor) safePath(name string) (path, rel string, err error) {
+	path, err = sanitizeArchivePath(e.target, name)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err = filepath.Rel(e.target, path)
+	return path, rel, err
+}
+
+// checkParents ensures no parent of the entry is a symlink, that could redirect the entry outside the target dir.
+func (e *extractor) checkParents(rel string) error {
+	dir := e.target
+	parts := strings.Split(filepath.Dir(rel), string(filepath.Separator))
+	for _, p := range parts {
+		if p == "." {
+			continue
+		}
+		dir = filepath.Join(dir, p)
+		fi, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: entry %s is located in the symlinked dir %s", ErrUnsafeEntry, rel, p)
+		}
+	}
+	return nil
+}
+
+// checkLink ensures the link target of the symlink resolves within the target dir.
+// Only relative links are allowed and '..' is only allowed at the beginning of the link,
+// so the link can not be redirected by other symlinks.
+func (e *extractor) checkLink(rel, link string) error {
+	if link == "" || filepath.IsAbs(link) || strings.HasPrefix(link, "/") || filepath.VolumeName(link) != "" {
+		return fmt.Errorf("%w: symlink %s: target %q must be a relative path", ErrUnsafeEntry, rel, link)
+	}
+	depth := len(strings.Split(filepath.Dir(rel), string(filepath.Separator)))
+	if filepath.Dir(rel) == "." {
+		depth = 0
+	}
+	descending := false
+	for p := range strings.FieldsFuncSeq(link, func(r rune) bool { return r == '/' || r == '\\' }) {
+		switch p {
+		case ".":
+		case "..":
+			if descending {
+				return fmt.Errorf("%w: symlink %s: target %q must not contain '..' after a path element",
+					ErrUnsafeEntry, rel, link)
+			}
+			if depth--; depth < 0 {
+				return fmt.Errorf("%w: symlink %s: target %q points outside of the target dir", ErrUnsafeEntry, rel, link)
+			}
+		default:
+			descending = true
+		}
+	}
+	return nil
+}
+
+// isWithin returns true if the relative path does not leave its base dir.
+func isWithin(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(in)
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(out)
+	_, err = io.Copy(out, in)
+	return err
+}
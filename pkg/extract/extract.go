@@ -4,6 +4,9 @@ package extract
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"errors"
 	"fmt"
@@ -13,185 +16,345 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/bodgit/sevenzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/xi2/xz"
 
 	"github.com/bakito/toolbox/pkg/quietly"
 )
 
-func File(file, target string) (bool, error) {
-	ln := strings.ToLower(file)
-	if strings.HasSuffix(ln, ".tar.gz") || strings.HasSuffix(ln, ".tgz") {
-		log.Printf("Extracting %s", file)
-		return true, tarGz(file, target)
+// compression a single stream compression, that may contain a tar archive or a single file.
+type compression struct {
+	name   string
+	magic  []byte
+	reader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	sevenZipMagic = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+	tarMagic      = []byte("ustar")
+
+	compressions = []compression{
+		{
+			name:  "gzip",
+			magic: []byte{0x1F, 0x8B},
+			reader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name:  "xz",
+			magic: []byte{0xFD, '7', 'z', 'X', 'Z', 0x00},
+			reader: func(r io.Reader) (io.ReadCloser, error) {
+				xr, err := xz.NewReader(r, 0)
+				return io.NopCloser(xr), err
+			},
+		},
+		{
+			name:  "bzip2",
+			magic: []byte("BZh"),
+			reader: func(r io.Reader) (io.ReadCloser, error) {
+				return io.NopCloser(bzip2.NewReader(r)), nil
+			},
+		},
+		{
+			name:  "zstd",
+			magic: []byte{0x28, 0xB5, 0x2F, 0xFD},
+			reader: func(r io.Reader) (io.ReadCloser, error) {
+				zr, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return zr.IOReadCloser(), nil
+			},
+		},
 	}
-	if strings.HasSuffix(ln, ".zip") {
-		log.Printf("Extracting %s", file)
-		return true, unzip(file, target)
+)
+
+// maxLinkPasses the max number of passes extracting link targets not matched by the filter, to resolve link chains.
+const maxLinkPasses = 5
+
+// Options the extraction options.
+type Options struct {
+	// Limits the size and entry limits of the extraction.
+	Limits Limits
+	// Filter if not nil, only entries with a name matching the filter are extracted.
+	// The name is the clean slash separated path of the entry in the archive.
+	// Targets of extracted symlinks and hardlinks are extracted as well, even if not matched.
+	Filter func(name string) bool
+	// Log the logger of the extraction, the standard logger if nil.
+	Log *log.Logger
+}
+
+// archive extracts the entries of an archive with the extractor.
+type archive func(e *extractor) error
+
+// File extracts the archive file into the target dir and returns the result of the extraction.
+// The format is detected by the magic bytes of the file, so mislabeled files are extracted as well.
+// Supported are zip, 7z and tar archives, optionally compressed with gzip, xz, bzip2 or zstd.
+// File modes, directories, symlinks and hardlinks are preserved, as long as they stay within the target dir.
+// A single file compressed with gzip, xz, bzip2 or zstd is decompressed in place and Result.Archive is false,
+// as the decompressed file is used as is.
+// A *LimitError is returned if the extraction exceeds the limits of the options,
+// an error wrapping ErrUnsafeEntry if an entry is invalid or would be written outside the target dir.
+func File(file, target string, opts Options) (*Result, error) {
+	logger := opts.Log
+	if logger == nil {
+		logger = log.Default()
 	}
-	if strings.HasSuffix(ln, ".tar.xz") || strings.HasSuffix(ln, ".txz") {
-		log.Printf("Extracting %s", file)
-		return true, tarXz(file, target)
+	header, err := readHeader(file)
+	if err != nil {
+		return nil, err
 	}
-	return false, nil
-}
 
-func unzip(file, target string) error {
-	read, err := zip.OpenReader(file)
+	var extractArchive archive
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		extractArchive = func(e *extractor) error { return unzip(file, e) }
+	case bytes.HasPrefix(header, sevenZipMagic):
+		extractArchive = func(e *extractor) error { return un7z(file, e) }
+	case isTar(header):
+		extractArchive = func(e *extractor) error { return untarFile(file, e, nil) }
+	default:
+		for _, c := range compressions {
+			if !bytes.HasPrefix(header, c.magic) {
+				continue
+			}
+			containsTar, err := compressedTar(file, c)
+			if err != nil {
+				return nil, fmt.Errorf("could not read %s file %s: %w", c.name, file, err)
+			}
+			if !containsTar {
+				logger.Printf("Decompressing %s", file)
+				return &Result{}, decompress(file, c, opts.Limits)
+			}
+			extractArchive = func(e *extractor) error { return untarFile(file, e, &c) }
+			break
+		}
+	}
+	if extractArchive == nil {
+		return &Result{}, nil
+	}
+
+	logger.Printf("Extracting %s", file)
+	e, err := newExtractor(target, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer quietly.Close(read)
-	for _, file := range read.File {
-		if file.Mode().IsDir() {
-			continue
+	if err := extractArchive(e); err != nil {
+		return nil, err
+	}
+	if opts.Filter == nil {
+		return e.result, nil
+	}
+
+	// extract the link targets not matched by the filter, so the extracted links are not dangling
+	for range maxLinkPasses {
+		missing := e.missingLinkTargets()
+		if len(missing) == 0 {
+			break
 		}
-		if err := unzipFile(file, target); err != nil {
-			return err
+		e.linkPass = true
+		e.pending = make(map[string]bool)
+		e.filter = func(name string) bool {
+			for m := range missing {
+				if name == m || strings.HasPrefix(name, m+"/") {
+					return true
+				}
+			}
+			return false
+		}
+		if err := extractArchive(e); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return e.result, nil
 }
 
-func unzipFile(file *zip.File, target string) error {
-	open, err := file.Open()
+// readHeader reads the first bytes of the file, enough to detect the format of the file including tar archives.
+func readHeader(file string) ([]byte, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer quietly.Close(f)
+	return readBlock(f)
+}
+
+func readBlock(r io.Reader) ([]byte, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
 	}
-	defer quietly.Close(open)
-	name, err := sanitizeArchivePath(target, file.Name)
+	return header[:n], nil
+}
+
+// isTar returns true if the header is the header of a tar archive.
+func isTar(header []byte) bool {
+	return len(header) >= 262 && bytes.HasPrefix(header[257:], tarMagic)
+}
+
+// compressedTar returns true if the compressed file contains a tar archive.
+func compressedTar(file string, c compression) (bool, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return err
+		return false, err
 	}
-	parent := filepath.Dir(name)
-	err = os.MkdirAll(parent, 0o755)
+	defer quietly.Close(f)
+	r, err := c.reader(bufio.NewReader(f))
 	if err != nil {
-		return err
+		return false, err
 	}
-	create, err := os.Create(name)
+	defer quietly.Close(r)
+	header, err := readBlock(r)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer quietly.Close(create)
-	_, err = create.ReadFrom(open)
-	return err
+	return isTar(header), nil
 }
 
-// sanitize archive file pathing from "G305: Zip Slip vulnerability".
-func sanitizeArchivePath(d, t string) (v string, err error) {
-	v = filepath.Join(d, t)
-	if strings.HasPrefix(v, filepath.Clean(d)) {
-		return v, nil
+// decompress replaces the compressed file with its decompressed content.
+func decompress(file string, c compression, limits Limits) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
 	}
-
-	return "", fmt.Errorf("%s: %s", "content filepath is tainted", t)
-}
-
-func tarGz(file, target string) error {
-	tarFile, err := os.Open(file)
+	defer quietly.Close(f)
+	r, err := c.reader(bufio.NewReader(f))
 	if err != nil {
 		return err
 	}
-	defer quietly.Close(tarFile)
+	defer quietly.Close(r)
 
-	uncompressedStream, err := gzip.NewReader(tarFile)
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".decompress-")
 	if err != nil {
-		return fmt.Errorf("ExtractTarGz: NewReader failed: %w", err)
+		return err
 	}
-	defer quietly.Close(uncompressedStream)
-
-	tarReader := tar.NewReader(uncompressedStream)
-
-	for {
-		header, err := tarReader.Next()
-
-		if errors.Is(err, io.EOF) {
-			break
-		}
-
-		if err != nil {
-			return fmt.Errorf("extractTarGz: Next() failed: %w", err)
-		}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	sizes := &sizeTracker{limits: limits.withDefaults()}
+	if err := sizes.copy(filepath.Base(file), tmp, r); err != nil {
+		quietly.Close(tmp)
+		return fmt.Errorf("could not decompress %s: %w", file, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// rename instead of overwriting, as the file might be a hard link to the download cache
+	return os.Rename(tmp.Name(), file)
+}
 
-		if header.Typeflag == tar.TypeReg {
-			if err := extractTarFile(target, header, tarReader); err != nil {
-				return err
-			}
+func unzip(file string, e *extractor) error {
+	read, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(read)
+	for _, file := range read.File {
+		if err := extractEntry(e, file.Name, file.Mode(), file.Open); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func extractTarFile(target string, header *tar.Header, tarReader *tar.Reader) error {
-	path, err := sanitizeArchivePath(target, header.Name)
+func un7z(file string, e *extractor) error {
+	read, err := sevenzip.OpenReader(file)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return fmt.Errorf("extractTarGz: Mkdir() failed: %w", err)
+	defer quietly.Close(read)
+	for _, file := range read.File {
+		if err := extractEntry(e, file.Name, file.Mode(), file.Open); err != nil {
+			return err
+		}
 	}
-	outFile, err := os.Create(path)
+	return nil
+}
+
+// extractEntry extracts a zip or 7z archive entry, where the content of a symlink is its target.
+func extractEntry(e *extractor, name string, mode os.FileMode, open func() (io.ReadCloser, error)) error {
+	if mode.IsDir() {
+		return e.dir(name, mode)
+	}
+	r, err := open()
 	if err != nil {
-		return fmt.Errorf("extractTarGz: Create() failed: %w", err)
+		return err
 	}
-	defer quietly.Close(outFile)
-	if _, err := io.Copy(outFile, tarReader); err != nil {
-		return fmt.Errorf("extractTarGz: Copy() failed: %w", err)
+	defer quietly.Close(r)
+	if mode&os.ModeSymlink != 0 {
+		link, err := io.ReadAll(io.LimitReader(r, maxLinkLength))
+		if err != nil {
+			return err
+		}
+		return e.symlink(name, string(link))
 	}
-	return nil
+	return e.file(name, mode, r)
 }
 
-func tarXz(file, target string) error {
-	// Open a file
+// sanitize archive file pathing from "G305: Zip Slip vulnerability".
+// The path must be within d, a sibling dir sharing the prefix of d (e.g. /tmp/xy for /tmp/x) is rejected.
+func sanitizeArchivePath(d, t string) (v string, err error) {
+	v = filepath.Join(d, t)
+	if rel, err := filepath.Rel(d, v); err == nil && isWithin(rel) {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("%w: content filepath is tainted: %s", ErrUnsafeEntry, t)
+}
+
+// untarFile extracts the tar archive file, decompressed with the given compression if not nil.
+func untarFile(file string, e *extractor, c *compression) error {
 	f, err := os.Open(file)
 	if err != nil {
 		return err
 	}
 	defer quietly.Close(f)
-	// Create a xz Reader
-	r, err := xz.NewReader(f, 0)
-	if err != nil {
-		return err
+
+	var r io.Reader = bufio.NewReader(f)
+	if c != nil {
+		cr, err := c.reader(r)
+		if err != nil {
+			return fmt.Errorf("could not read %s file %s: %w", c.name, file, err)
+		}
+		defer quietly.Close(cr)
+		r = cr
 	}
-	// Create a tar Reader
-	tr := tar.NewReader(r)
-	// Iterate through the files in the archive.
+	return untar(r, e)
+}
+
+func untar(r io.Reader, e *extractor) error {
+	tarReader := tar.NewReader(r)
 	for {
-		hdr, err := tr.Next()
+		header, err := tarReader.Next()
+
 		if errors.Is(err, io.EOF) {
-			// end of tar archive
 			break
 		}
+
 		if err != nil {
-			return err
+			return fmt.Errorf("untar: Next() failed: %w", err)
 		}
 
-		if err := tarXzFile(tr, hdr, target); err != nil {
-			return err
+		if err := extractTarEntry(e, header, tarReader); err != nil {
+			return fmt.Errorf("untar: %w", err)
 		}
 	}
 	return nil
 }
 
-func tarXzFile(tr *tar.Reader, hdr *tar.Header, target string) error {
-	path, err := sanitizeArchivePath(target, hdr.Name)
-	if err != nil {
-		return err
-	}
-	if hdr.Typeflag == tar.TypeReg {
-		// create parent directory
-		err = os.MkdirAll(filepath.Dir(path), 0o755)
-		if err != nil {
-			return err
-		}
-		// write a file
-		w, err := os.Create(path)
-		if err != nil {
-			return err
-		}
-
-		defer quietly.Close(w)
-		_, err = io.Copy(w, tr)
-		return err
+// extractTarEntry extracts files, dirs, symlinks and hardlinks, other entry types are skipped.
+func extractTarEntry(e *extractor, header *tar.Header, tarReader *tar.Reader) error {
+	switch header.Typeflag {
+	case tar.TypeReg:
+		return e.file(header.Name, header.FileInfo().Mode(), tarReader)
+	case tar.TypeDir:
+		return e.dir(header.Name, header.FileInfo().Mode())
+	case tar.TypeSymlink:
+		return e.symlink(header.Name, header.Linkname)
+	case tar.TypeLink:
+		return e.hardlink(header.Name, header.Linkname)
 	}
 	return nil
 }
@@ -1,8 +1,14 @@
 package extract_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -29,7 +35,11 @@ func TestExtract(t *testing.T) {
 		{name: "It should extract a tar.gz file with directories", file: "testfile-dirs.tar.gz", want: true},
 		{name: "It should extract a simple tar.xz file", file: "testfile.tar.xz", want: true},
 		{name: "It should extract a tar.xz file with directories", file: "testfile-dirs.tar.xz", want: true},
-		{name: "should not know the extension", file: "a.txt", want: false},
+		{name: "It should extract a simple tar.bz2 file", file: "testfile.tar.bz2", want: true},
+		{name: "It should extract a simple tar.zst file", file: "testfile.tar.zst", want: true},
+		{name: "It should extract a simple 7z file", file: "testfile.7z", want: true},
+		{name: "It should detect a mislabeled tar.gz file", file: "testfile-mislabeled.bin", want: true},
+		{name: "should not extract a file that is not an archive", file: "testfile", want: false},
 	}
 
 	for _, tt := range tests {
@@ -38,17 +48,20 @@ func TestExtract(t *testing.T) {
 			runTempDir := filepath.Join(tempDir, tt.file)
 			_ = os.MkdirAll(runTempDir, 0o755)
 
-			ok, err := extract.File("../../testdata/"+tt.file, runTempDir)
+			res, err := extract.File(filepath.Join("../../testdata", tt.file), runTempDir, extract.Options{})
 			if err != nil {
 				t.Errorf("extract.File() error = %v", err)
 				return
 			}
-			if ok != tt.want {
-				t.Errorf("extract.File() ok = %v, want %v", ok, tt.want)
+			if res.Archive != tt.want {
+				t.Errorf("extract.File() Archive = %v, want %v", res.Archive, tt.want)
 				return
 			}
 
-			if ok {
+			if res.Archive {
+				if got := res.Count(extract.TypeFile); got != 1 {
+					t.Errorf("expected 1 extracted file, got %v", got)
+				}
 				files, err := findFiles(runTempDir)
 				if err != nil {
 					t.Fatalf("findFiles() error = %v", err)
@@ -85,3 +98,378 @@ func findFiles(dir string) ([]string, error) {
 	})
 	return files, err
 }
+
+func TestDecompress(t *testing.T) {
+	testFile, err := os.ReadFile("../../testdata/testfile")
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+
+	for _, file := range []string{"testfile.gz", "testfile.xz", "testfile.bz2", "testfile.zst"} {
+		t.Run(file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("../../testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			// a mislabeled name, the compression is detected by the magic bytes
+			path := filepath.Join(dir, "tool")
+			if err := os.WriteFile(path, content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var logs bytes.Buffer
+			res, err := extract.File(path, dir, extract.Options{Log: log.New(&logs, "", 0)})
+			if err != nil {
+				t.Fatalf("extract.File() error = %v", err)
+			}
+			if res.Archive {
+				t.Error("extract.File() Archive = true, want false for a single compressed file")
+			}
+			if want := "Decompressing " + path + "\n"; logs.String() != want {
+				t.Errorf("Expected the decompression to be logged to the logger of the options: %q, got %q", want, logs.String())
+			}
+			files, err := findFiles(dir)
+			if err != nil {
+				t.Fatalf("findFiles() error = %v", err)
+			}
+			if diff := cmp.Diff([]string{path}, files); diff != "" {
+				t.Errorf("files mismatch (-want +got):\n%s", diff)
+			}
+			decompressed, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(testFile, decompressed); diff != "" {
+				t.Errorf("decompressed file mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExtractLinksAndModes(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "kubectx/", mode: 0o755 | os.ModeDir},
+		{name: "kubectx/lib/", mode: 0o755 | os.ModeDir},
+		{name: "kubectx/lib/kubectx.sh", mode: 0o755, content: "#!/bin/sh\necho kubectx\n"},
+		{name: "kubectx/lib/README", mode: 0o644, content: "readme"},
+		{name: "kubectx/bin/kubectx", mode: 0o777 | os.ModeSymlink, link: "../lib/kubectx.sh"},
+		{name: "kubectx/LICENSE", hardlink: "kubectx/lib/README"},
+	}
+	want := []extract.Entry{
+		{Path: filepath.FromSlash("kubectx"), Type: extract.TypeDir, Mode: 0o755},
+		{Path: filepath.FromSlash("kubectx/lib"), Type: extract.TypeDir, Mode: 0o755},
+		{Path: filepath.FromSlash("kubectx/lib/kubectx.sh"), Type: extract.TypeFile, Mode: 0o755},
+		{Path: filepath.FromSlash("kubectx/lib/README"), Type: extract.TypeFile, Mode: 0o644},
+		{Path: filepath.FromSlash("kubectx/bin/kubectx"), Type: extract.TypeSymlink, Link: "../lib/kubectx.sh"},
+	}
+
+	for _, format := range []string{"tar", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := writeArchive(t, format, entries)
+
+			res, err := extract.File(archive, dir, extract.Options{})
+			if err != nil {
+				t.Fatalf("extract.File() error = %v", err)
+			}
+			wantEntries := want
+			if format == "tar" {
+				wantEntries = append(wantEntries, extract.Entry{
+					Path: filepath.FromSlash("kubectx/LICENSE"), Type: extract.TypeHardlink, Mode: 0o644,
+					Link: filepath.FromSlash("kubectx/lib/README"),
+				})
+			}
+			if diff := cmp.Diff(wantEntries, res.Entries); diff != "" {
+				t.Errorf("Entries mismatch (-want +got):\n%s", diff)
+			}
+
+			fi, err := os.Stat(filepath.Join(dir, "kubectx", "lib", "kubectx.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm()&0o100 == 0 {
+				t.Errorf("expected kubectx.sh to be executable, got %v", fi.Mode())
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "kubectx", "bin", "kubectx"))
+			if err != nil {
+				t.Fatalf("could not read through the symlink: %v", err)
+			}
+			if string(content) != "#!/bin/sh\necho kubectx\n" {
+				t.Errorf("unexpected content of the symlinked file: %q", content)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsEscapingLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+	}{
+		{
+			name:    "absolute symlink",
+			entries: []archiveEntry{{name: "bin/tool", mode: 0o777 | os.ModeSymlink, link: "/etc/passwd"}},
+		},
+		{
+			name:    "symlink leaving the target",
+			entries: []archiveEntry{{name: "bin/tool", mode: 0o777 | os.ModeSymlink, link: "../../outside"}},
+		},
+		{
+			name: "symlink climbing through another symlink",
+			entries: []archiveEntry{
+				{name: "a/b/up", mode: 0o777 | os.ModeSymlink, link: ".."},
+				{name: "a/b/tool", mode: 0o777 | os.ModeSymlink, link: "up/../../outside"},
+			},
+		},
+		{
+			name: "file written through a symlinked dir",
+			entries: []archiveEntry{
+				{name: "lib", mode: 0o777 | os.ModeSymlink, link: "."},
+				{name: "lib/tool", mode: 0o755, content: "tool"},
+			},
+		},
+		{
+			name:    "hardlink leaving the target",
+			entries: []archiveEntry{{name: "tool", hardlink: "../outside"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"tar", "zip"} {
+			if format == "zip" && tt.entries[0].hardlink != "" {
+				continue
+			}
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				dir := filepath.Join(t.TempDir(), "target")
+				archive := writeArchive(t, format, tt.entries)
+				if _, err := extract.File(archive, dir, extract.Options{}); !errors.Is(err, extract.ErrUnsafeEntry) {
+					t.Errorf("extract.File() error = %v, want %v", err, extract.ErrUnsafeEntry)
+				}
+			})
+		}
+	}
+}
+
+type archiveEntry struct {
+	name     string
+	mode     os.FileMode
+	content  string
+	link     string
+	hardlink string
+}
+
+// writeArchive writes the entries as tar or zip archive.
+func writeArchive(t *testing.T, format string, entries []archiveEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive."+format)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if format == "zip" {
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			if e.hardlink != "" {
+				// zip does not support hardlinks
+				continue
+			}
+			fh := &zip.FileHeader{Name: e.name, Method: zip.Store}
+			fh.SetMode(e.mode)
+			w, err := zw.CreateHeader(fh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := e.content
+			if e.link != "" {
+				content = e.link
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case e.mode.IsDir():
+			h.Typeflag = tar.TypeDir
+		case e.link != "":
+			h.Typeflag, h.Linkname = tar.TypeSymlink, e.link
+		case e.hardlink != "":
+			h.Typeflag, h.Linkname = tar.TypeLink, e.hardlink
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractLimits(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "bin/", mode: 0o755 | os.ModeDir},
+		{name: "bin/tool", mode: 0o755, content: strings.Repeat("t", 100)},
+		{name: "bin/helper", mode: 0o755, content: strings.Repeat("h", 100)},
+	}
+	tests := []struct {
+		name     string
+		limits   extract.Limits
+		wantKind extract.LimitKind
+	}{
+		{name: "should extract within the default limits"},
+		{name: "should extract exactly at the limits", limits: extract.Limits{MaxTotalSize: 200, MaxEntrySize: 100, MaxEntries: 3}},
+		{name: "should extract with disabled limits", limits: extract.Limits{MaxTotalSize: -1, MaxEntrySize: -1, MaxEntries: -1}},
+		{name: "should fail on a too big entry", limits: extract.Limits{MaxEntrySize: 99}, wantKind: extract.LimitEntrySize},
+		{name: "should fail on a too big total size", limits: extract.Limits{MaxTotalSize: 150}, wantKind: extract.LimitTotalSize},
+		{name: "should fail on too many entries", limits: extract.Limits{MaxEntries: 2}, wantKind: extract.LimitEntries},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"tar", "zip"} {
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				archive := writeArchive(t, format, entries)
+				_, err := extract.File(archive, t.TempDir(), extract.Options{Limits: tt.limits})
+				if tt.wantKind == "" {
+					if err != nil {
+						t.Errorf("extract.File() error = %v", err)
+					}
+					return
+				}
+				le, ok := errors.AsType[*extract.LimitError](err)
+				if !ok {
+					t.Fatalf("extract.File() error = %v, want a LimitError", err)
+				}
+				if le.Kind != tt.wantKind {
+					t.Errorf("LimitError.Kind = %v, want %v", le.Kind, tt.wantKind)
+				}
+			})
+		}
+	}
+}
+
+func TestDecompressLimits(t *testing.T) {
+	content, err := os.ReadFile("../../testdata/testfile.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = extract.File(path, filepath.Dir(path), extract.Options{Limits: extract.Limits{MaxEntrySize: 10}})
+	if _, ok := errors.AsType[*extract.LimitError](err); !ok {
+		t.Fatalf("extract.File() error = %v, want a LimitError", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || !bytes.Equal(b, content) {
+		t.Errorf("expected the compressed file to be kept, got error %v", err)
+	}
+}
+
+func TestExtractRejectsSiblingDir(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "x")
+	// the sibling dir shares the prefix of the target dir
+	archive := writeArchive(t, "tar", []archiveEntry{{name: "../xy/tool", mode: 0o755, content: "tool"}})
+	if _, err := extract.File(archive, target, extract.Options{}); !errors.Is(err, extract.ErrUnsafeEntry) {
+		t.Errorf("extract.File() error = %v, want %v", err, extract.ErrUnsafeEntry)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "xy", "tool")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no file to be written to the sibling dir, got: %v", err)
+	}
+}
+
+func TestExtractFilter(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "sdk/", mode: 0o755 | os.ModeDir},
+		{name: "sdk/lib/", mode: 0o755 | os.ModeDir},
+		{name: "sdk/lib/kubectx.sh", mode: 0o755, content: "kubectx"},
+		{name: "sdk/lib/real-kubens", mode: 0o755, content: "kubens"},
+		{name: "sdk/docs/README", mode: 0o644, content: "readme"},
+		{name: "sdk/bin/kubectx", mode: 0o777 | os.ModeSymlink, link: "../lib/kubectx.sh"},
+		{name: "sdk/bin/kubens", hardlink: "sdk/lib/real-kubens"},
+	}
+	contents := []string{
+		"sdk/", "sdk/lib/", "sdk/lib/kubectx.sh", "sdk/lib/real-kubens",
+		"sdk/docs/README", "sdk/bin/kubectx", "sdk/bin/kubens",
+	}
+	tests := []struct {
+		name   string
+		format string
+		filter func(name string) bool
+		want   []string
+	}{
+		{
+			name:   "should extract a symlink with its target",
+			format: "tar",
+			filter: func(name string) bool { return filepath.Base(name) == "kubectx" },
+			want:   []string{"sdk/bin/kubectx", "sdk/lib/kubectx.sh"},
+		},
+		{
+			name:   "should extract a hardlink with its source",
+			format: "tar",
+			filter: func(name string) bool { return filepath.Base(name) == "kubens" },
+			want:   []string{"sdk/lib/real-kubens", "sdk/bin/kubens"},
+		},
+		{
+			name:   "should extract a zip symlink with its target",
+			format: "zip",
+			filter: func(name string) bool { return filepath.Base(name) == "kubectx" },
+			want:   []string{"sdk/bin/kubectx", "sdk/lib/kubectx.sh"},
+		},
+		{
+			name:   "should list the contents if nothing matches",
+			format: "tar",
+			filter: func(string) bool { return false },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := writeArchive(t, tt.format, entries)
+			res, err := extract.File(archive, dir, extract.Options{Filter: tt.filter})
+			if err != nil {
+				t.Fatalf("extract.File() error = %v", err)
+			}
+			var got []string
+			for _, e := range res.Entries {
+				got = append(got, filepath.ToSlash(e.Path))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Entries mismatch (-want +got):\n%s", diff)
+			}
+			wantContents := contents
+			if tt.format == "zip" {
+				// zip does not support hardlinks
+				wantContents = contents[:len(contents)-1]
+			}
+			if diff := cmp.Diff(wantContents, res.Contents); diff != "" {
+				t.Errorf("Contents mismatch (-want +got):\n%s", diff)
+			}
+			files, err := findFiles(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(tt.want) {
+				t.Errorf("expected %d extracted files, got %v", len(tt.want), files)
+			}
+			for _, f := range files {
+				if _, err := os.Stat(f); err != nil {
+					t.Errorf("expected %s to be readable: %v", f, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// DefaultMaxTotalSize the default max number of bytes extracted from a file.
+	DefaultMaxTotalSize int64 = 4 << 30
+	// DefaultMaxEntrySize the default max size of a single extracted entry.
+	DefaultMaxEntrySize int64 = 2 << 30
+	// DefaultMaxEntries the default max number of entries extracted from an archive.
+	DefaultMaxEntries = 100_000
+)
+
+// Limits the limits of an extraction, protecting against decompression bombs.
+// A zero value is replaced by the default, a negative value disables the limit.
+type Limits struct {
+	// MaxTotalSize the max number of bytes extracted from a file.
+	MaxTotalSize int64
+	// MaxEntrySize the max size of a single extracted entry.
+	MaxEntrySize int64
+	// MaxEntries the max number of entries extracted from an archive.
+	MaxEntries int
+}
+
+// withDefaults returns the limits with defaults for zero values.
+func (l Limits) withDefaults() Limits {
+	if l.MaxTotalSize == 0 {
+		l.MaxTotalSize = DefaultMaxTotalSize
+	}
+	if l.MaxEntrySize == 0 {
+		l.MaxEntrySize = DefaultMaxEntrySize
+	}
+	if l.MaxEntries == 0 {
+		l.MaxEntries = DefaultMaxEntries
+	}
+	return l
+}
+
+// LimitKind the kind of limit exceeded by an extraction.
+type LimitKind string
+
+const (
+	LimitTotalSize LimitKind = "total size"
+	LimitEntrySize LimitKind = "entry size"
+	LimitEntries   LimitKind = "entries"
+)
+
+// LimitError an extraction exceeded one of its limits.
+type LimitError struct {
+	Kind LimitKind
+	// Max the exceeded limit.
+	Max int64
+	// Entry the entry exceeding the limit.
+	Entry string
+}
+
+func (e *LimitError) Error() string {
+	if e.Kind == LimitEntries {
+		return fmt.Sprintf("extraction limit exceeded: more than %d entries", e.Max)
+	}
+	return fmt.Sprintf("extraction limit exceeded: %s of %d bytes exceeded by %s", e.Kind, e.Max, e.Entry)
+}
+
+// sizeTracker tracks the extracted bytes and entries against the limits.
+type sizeTracker struct {
+	limits  Limits
+	total   int64
+	entries int
+}
+
+// entry counts an extracted entry.
+func (t *sizeTracker) entry(name string) error {
+	t.entries++
+	if t.limits.MaxEntries > 0 && t.entries > t.limits.MaxEntries {
+		return &LimitError{Kind: LimitEntries, Max: int64(t.limits.MaxEntries), Entry: name}
+	}
+	return nil
+}
+
+// copy copies the content of an entry, until one of the size limits is exceeded.
+func (t *sizeTracker) copy(name string, w io.Writer, r io.Reader) error {
+	maxEntry := t.limits.MaxEntrySize
+	maxTotal := t.limits.MaxTotalSize
+	if maxEntry < 0 && maxTotal < 0 {
+		n, err := io.Copy(w, r)
+		t.total += n
+		return err
+	}
+
+	kind, limit := LimitEntrySize, maxEntry
+	if maxTotal >= 0 && (maxEntry < 0 || maxTotal-t.total < maxEntry) {
+		kind, limit = LimitTotalSize, maxTotal-t.total
+	}
+	n, err := io.Copy(w, io.LimitReader(r, limit+1))
+	t.total += n
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		exceeded := maxEntry
+		if kind == LimitTotalSize {
+			exceeded = maxTotal
+		}
+		return &LimitError{Kind: kind, Max: exceeded, Entry: name}
+	}
+	return nil
+}
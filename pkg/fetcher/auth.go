@@ -0,0 +1,92 @@
+package fetcher
+
+import (
+	"fmt"
+	http2 "net/http"
+	"net/url"
+	"os"
+
+	"github.com/bakito/toolbox/pkg/gitlab"
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// downloadHeader returns the auth headers of the download of the given url of the tool.
+// Assets of gitlab tools hosted on the gitlab instance of the tool are authenticated with the gitlab token.
+func downloadHeader(tool *types.Tool, rawURL string) (http2.Header, error) {
+	header := http2.Header{}
+	auth, err := authorization(tool, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if auth != "" {
+		header.Set("Authorization", auth)
+	}
+	if tool.Gitlab != "" {
+		gitlab.SetAssetToken(header, tool.GitlabURL, rawURL)
+	}
+	return header, nil
+}
+
+// authorization returns the 'Authorization' header of the requests of the tool to the given url.
+// An empty header is returned if the tool has no auth configured.
+func authorization(tool *types.Tool, rawURL string) (string, error) {
+	auth := tool.Auth
+	if auth == nil {
+		return "", nil
+	}
+	basic := auth.UsernameEnv != "" || auth.PasswordEnv != ""
+	methods := 0
+	for _, configured := range []bool{auth.BearerTokenEnv != "", basic, auth.Netrc} {
+		if configured {
+			methods++
+		}
+	}
+	if methods > 1 {
+		return "", fmt.Errorf("tool %s: only one of bearerTokenEnv, usernameEnv/passwordEnv or netrc can be configured", tool.Name)
+	}
+
+	switch {
+	case auth.BearerTokenEnv != "":
+		token, err := requiredEnv(tool, auth.BearerTokenEnv)
+		if err != nil {
+			return "", err
+		}
+		return http.BearerAuth(token), nil
+	case basic:
+		username, err := requiredEnv(tool, auth.UsernameEnv)
+		if err != nil {
+			return "", err
+		}
+		password, err := requiredEnv(tool, auth.PasswordEnv)
+		if err != nil {
+			return "", err
+		}
+		return http.BasicAuth(username, password), nil
+	case auth.Netrc:
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", err
+		}
+		login, password, found, err := http.LookupNetrc(u.Hostname())
+		if err != nil {
+			return "", fmt.Errorf("could not read netrc file: %w", err)
+		}
+		if !found {
+			return "", fmt.Errorf("tool %s: no netrc entry found for host %s", tool.Name, u.Hostname())
+		}
+		return http.BasicAuth(login, password), nil
+	}
+	return "", nil
+}
+
+func requiredEnv(tool *types.Tool, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("tool %s: usernameEnv and passwordEnv are required for basic auth", tool.Name)
+	}
+	v := os.Getenv(name)
+	if v == "" {
+		return "", fmt.Errorf("tool %s: env variable %s of the auth config is not set", tool.Name, name)
+	}
+	return v, nil
+}
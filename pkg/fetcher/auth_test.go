@@ -0,0 +1,155 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/gitlab"
+	pkghttp "github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestAuthorization(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrc, []byte("machine artifactory.example.com login netrc-user password netrc-pw\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(pkghttp.EnvNetrc, netrc)
+	t.Setenv("TEST_TOKEN", "token")
+	t.Setenv("TEST_USER", "user")
+	t.Setenv("TEST_PASSWORD", "pw")
+
+	tests := []struct {
+		name    string
+		auth    *types.Auth
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "No auth",
+		},
+		{
+			name: "Bearer token",
+			auth: &types.Auth{BearerTokenEnv: "TEST_TOKEN"},
+			want: "Bearer token",
+		},
+		{
+			name: "Basic auth",
+			auth: &types.Auth{UsernameEnv: "TEST_USER", PasswordEnv: "TEST_PASSWORD"},
+			want: pkghttp.BasicAuth("user", "pw"),
+		},
+		{
+			name: "Netrc",
+			auth: &types.Auth{Netrc: true},
+			url:  "https://artifactory.example.com/tool.tar.gz",
+			want: pkghttp.BasicAuth("netrc-user", "netrc-pw"),
+		},
+		{
+			name:    "Netrc without entry",
+			auth:    &types.Auth{Netrc: true},
+			url:     "https://nexus.example.com/tool.tar.gz",
+			wantErr: true,
+		},
+		{
+			name:    "Env variable not set",
+			auth:    &types.Auth{BearerTokenEnv: "TEST_UNDEFINED"},
+			wantErr: true,
+		},
+		{
+			name:    "Basic auth without password",
+			auth:    &types.Auth{UsernameEnv: "TEST_USER"},
+			wantErr: true,
+		},
+		{
+			name:    "Multiple methods",
+			auth:    &types.Auth{BearerTokenEnv: "TEST_TOKEN", Netrc: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorization(&types.Tool{Name: "tool", Auth: tt.auth}, tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authorization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("authorization() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizedRequests(t *testing.T) {
+	t.Setenv("TEST_TOKEN", "token")
+	var headers []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			headers = append(headers, r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("v1.0.0"))
+	}))
+	defer srv.Close()
+
+	tool := &types.Tool{Name: "tool", Auth: &types.Auth{BearerTokenEnv: "TEST_TOKEN"}}
+	v, err := fetchVersion(resty.New(), tool, srv.URL+"/version")
+	if err != nil {
+		t.Fatalf("fetchVersion() error = %v", err)
+	}
+	if v != "v1.0.0" {
+		t.Errorf("fetchVersion() = %v, want v1.0.0", v)
+	}
+
+	header, err := downloadHeader(tool, srv.URL+"/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFetcher(Options{})
+	f.quiet = true
+	if err := f.downloadFile(filepath.Join(t.TempDir(), "tool"), srv.URL+"/tool", header); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+
+	if len(headers) != 2 || headers[0] != "Bearer token" || headers[1] != "Bearer token" {
+		t.Errorf("Expected authorized requests, but got: %v", headers)
+	}
+}
+
+func TestFetchToolSendsGitlabToken(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	t.Setenv(gitlab.EnvGitlabToken, "token")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+
+	tool := &types.Tool{Name: "tool", Gitlab: "group/project", GitlabURL: srv.URL, Version: "v1.0.0"}
+	target := t.TempDir()
+	f := newFetcher(Options{})
+	f.quiet = true
+	if err := f.fetchTool(tool, "tool", srv.URL+"/api/v4/projects/1/packages/generic/tool/v1.0.0/tool", "",
+		t.TempDir(), target); err != nil {
+		t.Fatalf("fetchTool() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, binaryName("tool"))); err != nil {
+		t.Errorf("Expected the tool to be installed: %v", err)
+	}
+}
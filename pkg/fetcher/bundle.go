@@ -0,0 +1,336 @@
+package fetcher
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/extract"
+	"github.com/bakito/toolbox/pkg/quietly"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const bundleIndexFile = "index.yaml"
+
+// Bundle fetches the tools for each of the given platforms ('<os>/<arch>') and writes them to output.
+// The binaries of each platform are placed in a '<os>_<arch>' directory, next to an index with the
+// version of each tool and the sha256 of each binary.
+// If output ends with '.tar.gz' or '.tgz' a tarball is created, otherwise the bundle is written to the output dir.
+func Bundle(cfgFile, output string, platforms ...string) error {
+	pfs := []platform{hostPlatform()}
+	if len(platforms) != 0 {
+		pfs = nil
+		for _, p := range platforms {
+			pf, err := parsePlatform(p)
+			if err != nil {
+				return err
+			}
+			pfs = append(pfs, pf)
+		}
+	}
+
+	tb, _, err := ReadToolbox(cfgFile)
+	if err != nil {
+		return err
+	}
+	if tb.Aliases != nil {
+		aliases = *tb.Aliases
+	}
+
+	tmp, err := os.MkdirTemp("", "toolbox")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	dir := output
+	if isTarball(output) {
+		dir = filepath.Join(tmp, "bundle")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f := newFetcher(Options{})
+	if err := f.initCache(tb); err != nil {
+		return err
+	}
+	if err := f.initDownload(tb); err != nil {
+		return err
+	}
+	f.initExtract(tb)
+	client := resty.New()
+	if err := f.configureNetwork(tb, client); err != nil {
+		return err
+	}
+	index := &types.BundleIndex{Tools: make(map[string]*types.BundledTool)}
+
+	fmt.Println()
+	for _, tool := range tb.GetTools() {
+		bt, err := f.bundleTool(client, tb, tool, pfs, dir, tmp)
+		if err != nil {
+			return err
+		}
+		if bt != nil {
+			index.Tools[tool.Name] = bt
+		}
+	}
+
+	if err := SaveYamlFile(filepath.Join(dir, bundleIndexFile), index); err != nil {
+		return err
+	}
+	if isTarball(output) {
+		if err := writeTarGz(dir, output); err != nil {
+			return err
+		}
+	}
+	log.Printf("📦 Bundle written to %s", output)
+	return f.evictCache(tb)
+}
+
+// bundleTool resolves the version of the tool once and fetches the binaries of each platform.
+// Platforms the tool could not be found or validated for are not contained in the result.
+func (f *fetcher) bundleTool(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	pfs []platform,
+	dir, tmp string,
+) (*types.BundledTool, error) {
+	f.log.Printf("🛠  Processing %s\n", tool.Name)
+	defer fmt.Fprintln(f.out)
+
+	if tool.TreeInstall() {
+		f.log.Printf("⏭️ Skipping %s, tools with install mode %s can not be bundled", tool.Name, types.InstallModeTree)
+		return nil, nil
+	}
+
+	rel, err := f.resolveVersion(client, tb, tool, "")
+	if err != nil {
+		return nil, err
+	}
+	if tool.Source() == "" {
+		return nil, nil
+	}
+	assets, err := rel.assets(client, tool)
+	if err != nil {
+		return nil, err
+	}
+
+	bt := &types.BundledTool{Version: tool.Version, Platforms: make(map[string][]types.BundledFile)}
+	for _, pf := range pfs {
+		f.log.Printf("📦 Bundling %s for %s", tool.Name, pf)
+		pff := f.forPlatform(pf)
+		ptb := *tb
+		ptb.Target = filepath.Join(dir, pf.dirName())
+		if err := os.MkdirAll(ptb.Target, 0o755); err != nil {
+			return nil, err
+		}
+		ptmp := filepath.Join(tmp, pf.dirName(), tool.Name)
+
+		if tool.Source() == types.SourceDownloadURL {
+			url := parseTemplate(tool.DownloadURL, tool.Version, pf)
+			err = pff.fetchTool(tool, tool.Name, url, tool.Checksum, ptmp, ptb.Target)
+		} else {
+			err = pff.downloadAssets(client, &ptb, tool, assets, ptmp)
+		}
+		if err != nil {
+			if _, ok := errors.AsType[*validationError](err); !ok {
+				return nil, err
+			}
+			f.log.Printf("🚫 Skipping %s for %s: %v", tool.Name, pf, err)
+			continue
+		}
+
+		files, err := bundledFiles(ptb.Target, pf, append([]string{tool.Name}, tool.Additional...))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) != 0 {
+			bt.Platforms[pf.String()] = files
+		}
+	}
+	return bt, nil
+}
+
+// forPlatform returns a copy of the fetcher fetching the tools for the given platform.
+func (f *fetcher) forPlatform(pf platform) *fetcher {
+	pff := *f
+	pff.platform = pf
+	return &pff
+}
+
+// bundledFiles returns the binaries of the given names that exist in the platform dir.
+func bundledFiles(pfDir string, pf platform, names []string) ([]types.BundledFile, error) {
+	var files []types.BundledFile
+	for _, name := range names {
+		bin := pf.binaryName(name)
+		sum, _, err := fileSha256(filepath.Join(pfDir, bin))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		files = append(files, types.BundledFile{
+			Name:   name,
+			Path:   path.Join(pf.dirName(), bin),
+			Sha256: sum,
+		})
+	}
+	return files, nil
+}
+
+func isTarball(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// writeTarGz writes the content of the dir into a gzip compressed tarball.
+func writeTarGz(dir, output string) error {
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(out)
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer quietly.Close(file)
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	// the deferred close only cleans up on errors, a failed close might leave a truncated bundle
+	return out.Close()
+}
+
+// openBundle returns the directory and the index of the bundle. Tarballs are extracted into tmp.
+func openBundle(from, tmp string) (string, *types.BundleIndex, error) {
+	dir := from
+	if isTarball(from) {
+		dir = filepath.Join(tmp, "bundle")
+		if _, err := extract.File(from, dir, extract.Options{}); err != nil {
+			return "", nil, err
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, bundleIndexFile))
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read the index of bundle %s: %w", from, err)
+	}
+	index := &types.BundleIndex{}
+	if err := yaml.Unmarshal(b, index); err != nil {
+		return "", nil, err
+	}
+	log.Printf("📦 Installing from bundle %s", from)
+	return dir, index, nil
+}
+
+func (f *fetcher) handleBundledTool(
+	index *types.BundleIndex,
+	dir string,
+	ver map[string]string,
+	tb *types.Toolbox,
+	tool *types.Tool,
+) error {
+	f.log.Printf("🛠  Processing %s\n", tool.Name)
+	defer fmt.Fprintln(f.out)
+
+	bt := index.Tools[tool.Name]
+	if bt == nil || len(bt.Platforms[f.platform.String()]) == 0 {
+		return fmt.Errorf("tool %q is not bundled for platform %s", tool.Name, f.platform)
+	}
+	if pinned := tool.PinnedVersion(); pinned != "" && pinned != bt.Version {
+		f.log.Printf("⚠️ Bundled version %s differs from configured version %s", bt.Version, pinned)
+	}
+
+	currentVersion := ver[tool.Name]
+	tool.Version = bt.Version
+	f.log.Printf("📦 Bundled Version: %s", tool.Version)
+	if tool.Version == currentVersion {
+		f.log.Print("✅ Skipping since already bundled version\n")
+		f.planSkip(tool, currentVersion)
+		return nil
+	}
+
+	files := bt.Platforms[f.platform.String()]
+	if f.opts.DryRun {
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+		f.planDownload(tool, currentVersion, paths...)
+		return nil
+	}
+
+	for _, file := range files {
+		p := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if !strings.HasPrefix(p, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("bundled file %q is outside of the bundle", file.Path)
+		}
+		if err := verifyChecksum(p, "sha256:"+file.Sha256); err != nil {
+			f.log.Printf("🔏🚫 %v", err)
+			return err
+		}
+		f.log.Print("🔏 Checksum matches")
+		if err := f.moveToTarget(tool, file.Name, tb.Target, filepath.Dir(p), filepath.Base(p), file.Name != tool.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/extract"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected platform
+		wantErr  bool
+	}{
+		{name: "Valid platform", value: "linux/arm64", expected: platform{goos: "linux", goarch: "arm64"}},
+		{name: "Surrounding spaces", value: " windows/amd64 ", expected: platform{goos: "windows", goarch: "amd64"}},
+		{name: "Missing arch", value: "linux", wantErr: true},
+		{name: "Empty arch", value: "linux/", wantErr: true},
+		{name: "Too many parts", value: "linux/arm/v7", wantErr: true},
+		{name: "Unknown os", value: "linx/amd64", wantErr: true},
+		{name: "Unknown arch", value: "linux/x86_64", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parsePlatform(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if actual != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	// the test binary is a valid executable of the host platform
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Tools: map[string]*types.Tool{
+			"tool": {DownloadURL: srv.URL + "/{{ .Version }}/tool-{{ .OS }}-{{ .Arch }}{{ .FileExt }}", Version: "v1.0.0"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	host := hostPlatform()
+	output := filepath.Join(dir, "tools.tar.gz")
+	if err := Bundle(cfgFile, output, host.String(), "plan9/386"); err != nil {
+		t.Fatalf("Bundle() error = %v", err)
+	}
+
+	extracted := filepath.Join(dir, "extracted")
+	if _, err := extract.File(output, extracted, extract.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, _, err := fileSha256(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(extracted, bundleIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := &types.BundleIndex{}
+	if err := yaml.Unmarshal(b, index); err != nil {
+		t.Fatal(err)
+	}
+	expected := &types.BundleIndex{Tools: map[string]*types.BundledTool{
+		"tool": {
+			Version: "v1.0.0",
+			Platforms: map[string][]types.BundledFile{
+				host.String(): {{Name: "tool", Path: path.Join(host.dirName(), host.binaryName("tool")), Sha256: sum}},
+			},
+		},
+	}}
+	if diff := cmp.Diff(expected, index); diff != "" {
+		t.Errorf("Bundle() index mismatch (-want +got):\n%s", diff)
+	}
+
+	bundled, _, err := fileSha256(filepath.Join(extracted, host.dirName(), host.binaryName("tool")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundled != sum {
+		t.Errorf("Expected bundled binary sha256 %v, but got: %v", sum, bundled)
+	}
+}
+
+func TestFetchFromBundle(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, _, err := fileSha256(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	host := hostPlatform()
+	bundleDir := filepath.Join(dir, "bundle")
+	if err := os.MkdirAll(filepath.Join(bundleDir, host.dirName()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundleDir, host.dirName(), host.binaryName("tool")), b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sha256  string
+		invalid bool
+	}{
+		{name: "Install bundled tool", sha256: sum},
+		{name: "Checksum mismatch", sha256: otherSha256, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SaveYamlFile(filepath.Join(bundleDir, bundleIndexFile), &types.BundleIndex{
+				Tools: map[string]*types.BundledTool{
+					"tool": {
+						Version: "v1.0.0",
+						Platforms: map[string][]types.BundledFile{
+							host.String(): {{Name: "tool", Path: path.Join(host.dirName(), host.binaryName("tool")), Sha256: tt.sha256}},
+						},
+					},
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			cfgFile := filepath.Join(dir, toolboxConfFile)
+			if err := SaveYamlFile(cfgFile, &types.Toolbox{
+				Tools: map[string]*types.Tool{"tool": {Github: "example/tool"}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			target := filepath.Join(t.TempDir(), "bin")
+
+			err := New(Options{From: bundleDir, Target: target}).Fetch(cfgFile)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+
+			ver, err := readVersions(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			installed, _, err := fileSha256(filepath.Join(target, host.binaryName("tool")))
+			if tt.invalid {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Expected tool not to be installed, but got: %v", err)
+				}
+				if _, ok := ver["tool"]; ok {
+					t.Errorf("Expected no version of the invalid tool, but got: %v", ver["tool"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if installed != sum {
+				t.Errorf("Expected installed binary sha256 %v, but got: %v", sum, installed)
+			}
+			if ver["tool"] != "v1.0.0" {
+				t.Errorf("Expected version v1.0.0, but got: %v", ver["tool"])
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package fetcher
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/github"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// initCache enables the download cache unless it is disabled by option or config,
+// and the cache of the github API responses unless it is disabled by config.
+func (f *fetcher) initCache(tb *types.Toolbox) error {
+	responses, ttl, err := responseCache(tb)
+	if err != nil {
+		return err
+	}
+	f.responses = responses
+	f.responseTTL = ttl
+
+	if f.opts.NoCache || (tb.Cache != nil && tb.Cache.Disabled) {
+		return nil
+	}
+	if _, _, err := cacheLimits(tb); err != nil {
+		return err
+	}
+	c, err := cache.Default()
+	if err != nil {
+		log.Printf("⚠️ Download cache is not available: %v", err)
+		return nil
+	}
+	f.downloads = c
+	return nil
+}
+
+// responseCache returns the cache of the github API responses and the duration they are used without
+// revalidation. The cache is nil if it is disabled by config or not available.
+func responseCache(tb *types.Toolbox) (*cache.Cache, time.Duration, error) {
+	ttl, err := responseTTL(tb)
+	if err != nil {
+		return nil, 0, err
+	}
+	if tb.Cache != nil && tb.Cache.APIDisabled {
+		return nil, ttl, nil
+	}
+	c, err := cache.Default()
+	if err != nil {
+		log.Printf("⚠️ API response cache is not available: %v", err)
+		return nil, ttl, nil
+	}
+	return c, ttl, nil
+}
+
+// cachedGithubAPI returns the github API of the tool, caching the API responses if the cache is enabled.
+func (f *fetcher) cachedGithubAPI(tb *types.Toolbox, tool *types.Tool) *github.API {
+	return withResponseCache(githubAPI(tb, tool).WithLogger(f.log), f.responses, f.responseTTL, f.opts.Refresh)
+}
+
+// withResponseCache enables caching of the responses of the github API, if the response cache is enabled.
+func withResponseCache(api *github.API, responses *cache.Cache, ttl time.Duration, refresh bool) *github.API {
+	if responses != nil {
+		api.WithCache(responses, ttl, refresh)
+	}
+	return api
+}
+
+// fromCache provides the cached download of the url in the given version at path.
+// Cached files not matching the expected checksum are ignored.
+func (f *fetcher) fromCache(path, url, version, checksum string) bool {
+	if !f.cacheable(version, checksum) {
+		return false
+	}
+	cached, ok := f.downloads.Get(url, version)
+	if !ok {
+		return false
+	}
+	if checksum != "" && verifyChecksum(cached, checksum) != nil {
+		return false
+	}
+	if err := os.Link(cached, path); err != nil {
+		if err := f.copyFile(cached, path); err != nil {
+			return false
+		}
+	}
+	f.log.Printf("💾 Using cached download of %s", url)
+	return true
+}
+
+// toCache stores the downloaded file of the url in the given version in the cache.
+func (f *fetcher) toCache(url, version, checksum, path string) {
+	if !f.cacheable(version, checksum) {
+		return
+	}
+	if err := f.downloads.Put(url, version, path); err != nil {
+		f.log.Printf("⚠️ Could not cache download of %s: %v", url, err)
+	}
+}
+
+// cacheable returns true if the cache is enabled and the download is identified by a version or checksum.
+// Without either, the content of the url might change unnoticed.
+func (f *fetcher) cacheable(version, checksum string) bool {
+	return f.downloads != nil && (version != "" || checksum != "")
+}
+
+// evictCache deletes the cache entries exceeding the configured limits.
+func (f *fetcher) evictCache(tb *types.Toolbox) error {
+	c := f.downloads
+	if c == nil {
+		c = f.responses
+	}
+	if c == nil {
+		return nil
+	}
+	maxAge, maxSize, err := cacheLimits(tb)
+	if err != nil {
+		return err
+	}
+	evicted, err := c.Evict(maxAge, maxSize)
+	if err != nil {
+		return err
+	}
+	if len(evicted) != 0 {
+		log.Printf("🧹 Evicted %d entries from the download cache", len(evicted))
+	}
+	return nil
+}
+
+func responseTTL(tb *types.Toolbox) (time.Duration, error) {
+	if tb.Cache == nil || tb.Cache.APITTL == "" {
+		return cache.DefaultResponseTTL, nil
+	}
+	ttl, err := time.ParseDuration(tb.Cache.APITTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache api ttl %q: %w", tb.Cache.APITTL, err)
+	}
+	return ttl, nil
+}
+
+func cacheLimits(tb *types.Toolbox) (maxAge time.Duration, maxSize int64, err error) {
+	maxAge = cache.DefaultMaxAge
+	maxSize = cache.DefaultMaxSize
+	if tb.Cache == nil {
+		return maxAge, maxSize, nil
+	}
+	if tb.Cache.MaxAge != "" {
+		if maxAge, err = time.ParseDuration(tb.Cache.MaxAge); err != nil {
+			return 0, 0, fmt.Errorf("invalid cache max age %q: %w", tb.Cache.MaxAge, err)
+		}
+	}
+	if tb.Cache.MaxSizeMB > 0 {
+		maxSize = tb.Cache.MaxSizeMB << 20
+	}
+	return maxAge, maxSize, nil
+}
@@ -0,0 +1,179 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestFetchToolUsesCache(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+
+	f := newFetcher(Options{})
+	f.downloads = cache.New(t.TempDir())
+	tool := &types.Tool{Name: "tool", Version: "v1.0.0"}
+
+	for range 2 {
+		target := t.TempDir()
+		if err := f.fetchTool(tool, "tool", srv.URL+"/tool", "", t.TempDir(), target); err != nil {
+			t.Fatalf("fetchTool() error = %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(target, binaryName("tool"))); err != nil {
+			t.Fatalf("Expected tool to be installed: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected 1 download, but got %d", requests.Load())
+	}
+
+	// a cached file not matching the checksum is downloaded again
+	if err := f.fetchTool(tool, "tool", srv.URL+"/tool", otherSha256, t.TempDir(), t.TempDir()); err == nil {
+		t.Error("Expected checksum mismatch")
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected 2 downloads, but got %d", requests.Load())
+	}
+}
+
+func TestFetchToolCachesPerVersion(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		versions     []string
+		wantRequests int32
+	}{
+		{name: "Same version", versions: []string{"v1.0.0", "v1.0.0"}, wantRequests: 1},
+		{name: "Other version of the same url", versions: []string{"v1.0.0", "v2.0.0"}, wantRequests: 2},
+		{name: "No version and checksum", versions: []string{"", ""}, wantRequests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			f := newFetcher(Options{})
+			f.downloads = cache.New(t.TempDir())
+			for _, v := range tt.versions {
+				tool := &types.Tool{Name: "tool", Version: v}
+				if err := f.fetchTool(tool, "tool", srv.URL+"/tool", "", t.TempDir(), t.TempDir()); err != nil {
+					t.Fatalf("fetchTool() error = %v", err)
+				}
+			}
+			if requests.Load() != tt.wantRequests {
+				t.Errorf("Expected %d downloads, but got %d", tt.wantRequests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *types.CacheConfig
+		wantMaxAge  string
+		wantMaxSize int64
+		wantErr     bool
+	}{
+		{name: "Defaults", wantMaxAge: cache.DefaultMaxAge.String(), wantMaxSize: cache.DefaultMaxSize},
+		{
+			name:        "Configured",
+			cfg:         &types.CacheConfig{MaxAge: "24h", MaxSizeMB: 10},
+			wantMaxAge:  "24h0m0s",
+			wantMaxSize: 10 << 20,
+		},
+		{name: "Invalid max age", cfg: &types.CacheConfig{MaxAge: "1 week"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxAge, maxSize, err := cacheLimits(&types.Toolbox{Cache: tt.cfg})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if maxAge.String() != tt.wantMaxAge {
+				t.Errorf("Expected max age: %v, but got: %v", tt.wantMaxAge, maxAge)
+			}
+			if maxSize != tt.wantMaxSize {
+				t.Errorf("Expected max size: %v, but got: %v", tt.wantMaxSize, maxSize)
+			}
+		})
+	}
+}
+
+func TestInitCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          Options
+		cfg           *types.CacheConfig
+		wantDownloads bool
+		wantResponses bool
+		wantTTL       time.Duration
+		wantErr       bool
+	}{
+		{name: "Defaults", wantDownloads: true, wantResponses: true, wantTTL: cache.DefaultResponseTTL},
+		{name: "No cache option", opts: Options{NoCache: true}, wantResponses: true, wantTTL: cache.DefaultResponseTTL},
+		{
+			name:          "Downloads disabled",
+			cfg:           &types.CacheConfig{Disabled: true, APITTL: "1h"},
+			wantResponses: true,
+			wantTTL:       time.Hour,
+		},
+		{
+			name:          "API responses disabled",
+			cfg:           &types.CacheConfig{APIDisabled: true},
+			wantDownloads: true,
+			wantTTL:       cache.DefaultResponseTTL,
+		},
+		{name: "Invalid api ttl", cfg: &types.CacheConfig{Disabled: true, APITTL: "1 hour"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(cache.EnvCacheDir, t.TempDir())
+			f := newFetcher(tt.opts)
+			err := f.initCache(&types.Toolbox{Cache: tt.cfg})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("initCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (f.downloads != nil) != tt.wantDownloads {
+				t.Errorf("Expected download cache enabled: %v, but got: %v", tt.wantDownloads, f.downloads != nil)
+			}
+			if (f.responses != nil) != tt.wantResponses {
+				t.Errorf("Expected response cache enabled: %v, but got: %v", tt.wantResponses, f.responses != nil)
+			}
+			if f.responseTTL != tt.wantTTL {
+				t.Errorf("Expected response ttl: %v, but got: %v", tt.wantTTL, f.responseTTL)
+			}
+		})
+	}
+}
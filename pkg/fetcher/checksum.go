@@ -0,0 +1,182 @@
+package fetcher
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/quietly"
+	"github.com/bakito/toolbox/pkg/types"
+	"github.com/bakito/toolbox/version"
+)
+
+var (
+	// checksumAssetSuffixes suffixes of checksum files published for a single asset.
+	checksumAssetSuffixes = []string{".sha256", ".sha256sum", ".sha512", ".sha512sum"}
+	// checksumSignatureSuffixes suffixes of signatures of checksum files, which are never checksum files themselves.
+	checksumSignatureSuffixes = []string{".sig", ".asc", ".pem", ".minisig", ".sigstore", ".bundle"}
+)
+
+// findChecksumAssets returns the checksum assets of a release that may contain the checksum of the given asset.
+// Assets dedicated to the given asset are returned first, followed by the aggregated checksum files.
+func findChecksumAssets(asset *types.Asset, assets []types.Asset) []types.Asset {
+	var dedicated, aggregated []types.Asset
+	for _, a := range assets {
+		ln := strings.ToLower(a.Name)
+		if a.Name == asset.Name || hasAnySuffix(ln, checksumSignatureSuffixes...) {
+			continue
+		}
+		for _, suffix := range checksumAssetSuffixes {
+			if a.Name == asset.Name+suffix {
+				dedicated = append(dedicated, a)
+			}
+		}
+		if isAggregatedChecksumFile(ln) {
+			aggregated = append(aggregated, a)
+		}
+	}
+
+	// prefer sha256 files
+	slices.SortStableFunc(aggregated, func(a, b types.Asset) int {
+		mi := strings.Contains(strings.ToLower(a.Name), "sha256")
+		mj := strings.Contains(strings.ToLower(b.Name), "sha256")
+		if mi == mj {
+			return 0
+		}
+		if mi {
+			return -1
+		}
+		return 1
+	})
+	return append(dedicated, aggregated...)
+}
+
+func isAggregatedChecksumFile(ln string) bool {
+	return strings.Contains(ln, "checksum") ||
+		strings.HasSuffix(ln, "sums") ||
+		strings.HasSuffix(ln, "sums.txt")
+}
+
+// lookupChecksum resolves the published checksum of the given release asset.
+// An empty result is returned if the release does not provide a checksum for the asset.
+func (f *fetcher) lookupChecksum(client *resty.Client, asset *types.Asset, assets []types.Asset) (string, error) {
+	for _, ca := range findChecksumAssets(asset, assets) {
+		resp, err := client.R().
+			SetHeader("User-Agent", "toolbox/"+version.Version).
+			Get(ca.BrowserDownloadURL)
+		if err != nil {
+			return "", http.CheckError(err)
+		}
+		if resp.IsError() {
+			return "", fmt.Errorf("could not download checksum file %s (%d)", ca.BrowserDownloadURL, resp.StatusCode())
+		}
+		if sum := parseChecksum(string(resp.Body()), asset.Name); sum != "" {
+			f.log.Printf("🔏 Found checksum in %s", ca.Name)
+			return sum, nil
+		}
+	}
+	return "", nil
+}
+
+// parseChecksum returns the checksum for the given file name from the content of a checksum file.
+// Supported are the GNU coreutils format ('<hash>  <file>'), the BSD format ('SHA256 (<file>) = <hash>')
+// and files containing the hash only.
+func parseChecksum(content, fileName string) string {
+	var single []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if algo, rest, ok := strings.Cut(line, " ("); ok && !strings.Contains(algo, " ") {
+			if name, sum, ok := strings.Cut(rest, ") = "); ok && name == fileName && isHexHash(sum) {
+				return strings.ToLower(sum)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			if isHexHash(fields[0]) {
+				single = append(single, fields[0])
+			}
+		case 2:
+			name := strings.TrimPrefix(fields[1], "*")
+			if (name == fileName || filepath.Base(name) == fileName) && isHexHash(fields[0]) {
+				return strings.ToLower(fields[0])
+			}
+		}
+	}
+	if len(single) == 1 {
+		return strings.ToLower(single[0])
+	}
+	return ""
+}
+
+func isHexHash(s string) bool {
+	if len(s) != sha256.Size*2 && len(s) != sha512.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// verifyChecksum compares the hash of the file with the expected checksum.
+// The checksum may be prefixed with the algorithm ('sha256:' or 'sha512:'),
+// otherwise the algorithm is derived from the length of the checksum.
+func verifyChecksum(path, expected string) error {
+	algo, sum, ok := strings.Cut(strings.TrimSpace(expected), ":")
+	if !ok {
+		sum = algo
+		algo = ""
+	}
+	algo = strings.ToLower(algo)
+	sum = strings.ToLower(sum)
+
+	var h hash.Hash
+	switch {
+	case algo == "sha256" || (algo == "" && len(sum) == sha256.Size*2):
+		h = sha256.New()
+	case algo == "sha512" || (algo == "" && len(sum) == sha512.Size*2):
+		h = sha512.New()
+	default:
+		return ValidationError("unsupported checksum %q", expected)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer quietly.Close(f)
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != sum {
+		return ValidationError("checksum mismatch for %s: expected %s but was %s", filepath.Base(path), sum, actual)
+	}
+	return nil
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package fetcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const (
+	testfileSha256 = "cd1e8aec61013483fde4d26e3d1b4693140010c5af0f11d4f5e1f9c621f0f2b8"
+	otherSha256    = "ca978112ca1bbdcafac231b39a23dc4da786eff8146d5e4aa9dd5f8c2c3e6f16"
+)
+
+func TestFindChecksumAssets(t *testing.T) {
+	tests := []struct {
+		name     string
+		asset    string
+		assets   []string
+		expected []string
+	}{
+		{
+			name:     "No checksum assets",
+			asset:    "tool-linux-amd64.tar.gz",
+			assets:   []string{"tool-linux-amd64.tar.gz", "tool-darwin-amd64.tar.gz"},
+			expected: nil,
+		},
+		{
+			name:  "Prefer dedicated checksum file",
+			asset: "tool-linux-amd64.tar.gz",
+			assets: []string{
+				"checksums.txt",
+				"tool-linux-amd64.tar.gz",
+				"tool-linux-amd64.tar.gz.sha256",
+				"tool-darwin-amd64.tar.gz.sha256",
+			},
+			expected: []string{"tool-linux-amd64.tar.gz.sha256", "checksums.txt"},
+		},
+		{
+			name:     "Ignore signatures of checksum files",
+			asset:    "tool-linux-amd64",
+			assets:   []string{"tool-linux-amd64", "checksums.txt.sig", "checksums.txt.pem", "tool_1.0.0_checksums.txt"},
+			expected: []string{"tool_1.0.0_checksums.txt"},
+		},
+		{
+			name:     "Prefer sha256 aggregated files",
+			asset:    "tool-linux-amd64",
+			assets:   []string{"SHA512SUMS", "SHA256SUMS", "tool-linux-amd64"},
+			expected: []string{"SHA256SUMS", "SHA512SUMS"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var assets []types.Asset
+			for _, a := range tt.assets {
+				assets = append(assets, types.Asset{Name: a})
+			}
+			var actual []string
+			for _, a := range findChecksumAssets(&types.Asset{Name: tt.asset}, assets) {
+				actual = append(actual, a.Name)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("findChecksumAssets() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		fileName string
+		expected string
+	}{
+		{
+			name:     "GNU format",
+			content:  otherSha256 + "  tool-darwin-amd64\n" + testfileSha256 + "  tool-linux-amd64\n",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "GNU binary format",
+			content:  testfileSha256 + " *tool-linux-amd64\n",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "GNU format with path",
+			content:  testfileSha256 + "  ./dist/tool-linux-amd64\n",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "BSD format",
+			content:  "SHA256 (tool-linux-amd64) = " + testfileSha256 + "\n",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "Hash only",
+			content:  testfileSha256 + "\n",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "Upper case hash",
+			content:  "CD1E8AEC61013483FDE4D26E3D1B4693140010C5AF0F11D4F5E1F9C621F0F2B8  tool-linux-amd64",
+			fileName: "tool-linux-amd64",
+			expected: testfileSha256,
+		},
+		{
+			name:     "File not listed",
+			content:  otherSha256 + "  tool-darwin-amd64\n",
+			fileName: "tool-linux-amd64",
+			expected: "",
+		},
+		{
+			name:     "Invalid hash",
+			content:  "abc  tool-linux-amd64\n",
+			fileName: "tool-linux-amd64",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := parseChecksum(tt.content, tt.fileName); actual != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testfile")
+	b, err := os.ReadFile("../../testdata/testfile")
+	if err != nil {
+		t.Fatalf("failed to read test file: %v", err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		checksum  string
+		wantErr   bool
+		wantValid bool
+	}{
+		{name: "matching checksum", checksum: testfileSha256},
+		{name: "matching checksum with algorithm", checksum: "sha256:" + testfileSha256},
+		{name: "matching upper case checksum", checksum: "SHA256:CD1E8AEC61013483FDE4D26E3D1B4693140010C5AF0F11D4F5E1F9C621F0F2B8"},
+		{name: "checksum mismatch", checksum: otherSha256, wantErr: true, wantValid: true},
+		{name: "unsupported checksum", checksum: "md5:abc", wantErr: true, wantValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChecksum(path, tt.checksum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := errors.AsType[*validationError](err); ok != tt.wantValid {
+				t.Errorf("verifyChecksum() validationError = %v, want %v", ok, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	http2 "net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+	"github.com/bakito/toolbox/version"
+)
+
+const (
+	defaultDownloadRetries = 3
+	defaultDownloadTimeout = 10 * time.Minute
+)
+
+var (
+	// downloadRetryBackoff the initial wait duration between download attempts, doubled with each retry.
+	downloadRetryBackoff = 2 * time.Second
+	// maxDownloadRetryWait the maximum duration to wait before a download attempt.
+	maxDownloadRetryWait = time.Minute
+
+	sleep = time.Sleep
+
+	// errPartialChanged the content of a partial download changed on the server.
+	errPartialChanged = errors.New("content changed since the partial download was started")
+)
+
+// DownloadError a download failed, either due to a network error or an error status of the server.
+type DownloadError struct {
+	URL string
+	// StatusCode the http status of the server, 0 if the download failed due to a network error.
+	StatusCode int
+	// Attempts the number of download attempts.
+	Attempts int
+	Err      error
+}
+
+func (e *DownloadError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("download of %s failed: server responded with %d %s",
+			e.URL, e.StatusCode, http2.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("download of %s failed after %d attempt(s): %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// NetworkError returns true if the download failed due to a network error.
+func (e *DownloadError) NetworkError() bool {
+	return e.StatusCode == 0
+}
+
+// initDownload applies the download config.
+func (f *fetcher) initDownload(tb *types.Toolbox) error {
+	if tb.Download == nil {
+		return nil
+	}
+	if tb.Download.Retries != nil {
+		if *tb.Download.Retries < 0 {
+			return fmt.Errorf("invalid download retries %d: must not be negative", *tb.Download.Retries)
+		}
+		f.retries = *tb.Download.Retries
+	}
+	if tb.Download.Timeout != "" {
+		timeout, err := time.ParseDuration(tb.Download.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid download timeout %q: %w", tb.Download.Timeout, err)
+		}
+		f.timeout = timeout
+	}
+	return nil
+}
+
+// downloadFile downloads the url to path. Network errors and server errors are retried with an exponential backoff.
+// If the cache is enabled, the download is written to a partial file in the cache first,
+// that allows resuming an interrupted download with a range request, as long as the content did not change.
+// The partial file is locked during the download, concurrent downloads of the url are downloaded without it.
+// The given header, e.g. the auth of the tool, is sent with every request.
+func (f *fetcher) downloadFile(path, url string, header http2.Header) error {
+	dest := path
+	if f.downloads != nil {
+		partial, unlock, err := f.downloads.PartialFile(url)
+		switch {
+		case errors.Is(err, cache.ErrPartialLocked):
+			f.log.Printf("⚠️ Could not resume downloads of %s, it is locked by another download", url)
+		case err != nil:
+			f.log.Printf("⚠️ Could not resume downloads of %s: %v", url, err)
+		default:
+			defer unlock()
+			dest = partial
+		}
+	}
+
+	var err error
+	attempt := 0
+	for ; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			wait := min(downloadRetryBackoff<<(attempt-1), maxDownloadRetryWait)
+			f.log.Printf("🔁 Download failed: %v, retrying in %s (%d/%d)", err, wait, attempt, f.retries)
+			sleep(wait)
+		}
+		if err = f.downloadAttempt(dest, url, header, dest != path); err == nil || !retryable(err) {
+			break
+		}
+	}
+	if err != nil {
+		return downloadError(url, min(attempt+1, f.retries+1), err)
+	}
+
+	if dest != path {
+		if err := f.moveFile(dest, path); err != nil {
+			return err
+		}
+		if err := cache.RemovePartial(dest); err != nil {
+			return err
+		}
+	}
+	f.log.Printf("Download saved to %s", path)
+	return nil
+}
+
+// downloadAttempt downloads the url to path. If partial is enabled, path is a partial download that is resumed
+// with an 'If-Range' request, if the validator of the response it was started with is known.
+// If the content changed in the meantime, the partial download is discarded and the download restarted.
+func (f *fetcher) downloadAttempt(path, url string, header http2.Header, partial bool) error {
+	var validator string
+	if partial {
+		if validator = cache.PartialValidator(path); validator == "" {
+			// without validator, a changed content could not be detected
+			if err := cache.RemovePartial(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	req, err := grab.NewRequest(path, url)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.HTTPRequest.Header[name] = values
+	}
+	req.HTTPRequest.Header.Set("User-Agent", "toolbox/"+version.Version)
+	// without a partial download, the content of a failed attempt can not be validated and is downloaded again
+	req.NoResume = !partial
+	if partial {
+		if validator != "" {
+			req.HTTPRequest.Header.Set("If-Range", validator)
+		}
+		req.BeforeCopy = func(resp *grab.Response) error {
+			if resp.DidResume {
+				// the server answers 200 with the full content if the validator does not match
+				if resp.HTTPResponse.StatusCode != http2.StatusPartialContent {
+					return errPartialChanged
+				}
+				return nil
+			}
+			return cache.SetPartialValidator(path, responseValidator(resp.HTTPResponse))
+		}
+	}
+	if f.timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	resp := f.grabClient.Do(req)
+
+	t := time.NewTicker(200 * time.Millisecond)
+	defer t.Stop()
+
+Loop:
+	for {
+		select {
+		case <-t.C:
+			if !f.quiet {
+				fmt.Fprintf(f.out, "\r  %s / %s (%.2f%%) %s/s            ",
+					FormatBytes(resp.BytesComplete()),
+					FormatBytes(resp.Size()),
+					100*resp.Progress(),
+					FormatBytes(int64(resp.BytesPerSecond())))
+			}
+
+		case <-resp.Done:
+			if !f.quiet {
+				fmt.Fprint(f.out, "\r")
+			}
+			fmt.Fprintf(f.out, "  %s / %s (%.2f%%) %s/s            ",
+				FormatBytes(resp.BytesComplete()),
+				FormatBytes(resp.Size()),
+				100*resp.Progress(),
+				FormatBytes(int64(resp.BytesPerSecond())))
+			fmt.Fprintln(f.out)
+			break Loop
+		}
+	}
+
+	err = resp.Err()
+	if err == nil && partial && resp.DidResume && resp.HTTPResponse.Request.Method == http2.MethodHead &&
+		responseValidator(resp.HTTPResponse) != validator {
+		// the partial download is complete, but does not match the current content
+		err = errPartialChanged
+	}
+	if errors.Is(err, errPartialChanged) {
+		f.log.Printf("🔄 Restarting the download of %s, the content changed since it was interrupted", url)
+		if err := cache.RemovePartial(path); err != nil {
+			return err
+		}
+		return f.downloadAttempt(path, url, header, partial)
+	}
+	if resp.DidResume {
+		f.log.Printf("⏯️ Resumed download of %s", url)
+	}
+	return err
+}
+
+// responseValidator returns the strong ETag or the Last-Modified date of the response,
+// that identify the content of the response in an 'If-Range' request.
+func responseValidator(resp *http2.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// moveFile moves the downloaded file from the cache to path.
+func (f *fetcher) moveFile(from, path string) error {
+	if err := os.Rename(from, path); err == nil {
+		return nil
+	}
+	if err := f.copyFile(from, path); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// retryable returns true if the download error is a network error, a timeout or an error status of the server.
+// Client errors like 404 are not retried.
+func retryable(err error) bool {
+	if code, ok := errors.AsType[grab.StatusCodeError](err); ok {
+		return code >= http2.StatusInternalServerError ||
+			code == http2.StatusRequestTimeout ||
+			code == http2.StatusTooManyRequests
+	}
+	return isNetworkError(err)
+}
+
+func isNetworkError(err error) bool {
+	if _, ok := errors.AsType[net.Error](err); ok {
+		return true
+	}
+	if _, ok := errors.AsType[*url.Error](err); ok {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+func downloadError(url string, attempts int, err error) error {
+	if code, ok := errors.AsType[grab.StatusCodeError](err); ok {
+		return &DownloadError{URL: url, StatusCode: int(code), Attempts: attempts, Err: err}
+	}
+	if isNetworkError(err) {
+		return &DownloadError{URL: url, Attempts: attempts, Err: http.CheckError(err)}
+	}
+	return err
+}
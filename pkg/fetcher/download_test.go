@@ -0,0 +1,298 @@
+package fetcher
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+type downloadResponse int
+
+const (
+	serveContent downloadResponse = iota
+	dropConnection
+	closeConnection
+	serverError
+	notFound
+	// changedContent serves a new content, not matching the validator of a partial download
+	changedContent
+	// ignoreRange serves the full content with status 200 to a range request
+	ignoreRange
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	changed := bytes.Repeat([]byte("9876543210"), 1000)
+	tests := []struct {
+		name           string
+		noCache        bool
+		responses      []downloadResponse
+		wantContent    []byte
+		wantRequests   int
+		wantRanges     []string
+		wantWaits      []time.Duration
+		wantStatusCode int
+		wantNetworkErr bool
+	}{
+		{
+			name:         "should resume a dropped download",
+			responses:    []downloadResponse{dropConnection},
+			wantRequests: 2,
+			wantRanges:   []string{"", "bytes=5000-"},
+			wantWaits:    []time.Duration{time.Millisecond},
+		},
+		{
+			name:         "should restart a dropped download without cache",
+			noCache:      true,
+			responses:    []downloadResponse{dropConnection, changedContent},
+			wantContent:  changed,
+			wantRequests: 2,
+			wantRanges:   []string{"", ""},
+			wantWaits:    []time.Duration{time.Millisecond},
+		},
+		{
+			name:         "should restart a dropped download if the content changed",
+			responses:    []downloadResponse{dropConnection, changedContent, changedContent},
+			wantContent:  changed,
+			wantRequests: 3,
+			wantRanges:   []string{"", "bytes=5000-", ""},
+			wantWaits:    []time.Duration{time.Millisecond},
+		},
+		{
+			name:         "should restart a dropped download if the server ignores the range",
+			responses:    []downloadResponse{dropConnection, ignoreRange},
+			wantRequests: 3,
+			wantRanges:   []string{"", "bytes=5000-", ""},
+			wantWaits:    []time.Duration{time.Millisecond},
+		},
+		{
+			name:         "should retry server errors",
+			responses:    []downloadResponse{serverError, serverError},
+			wantRequests: 3,
+			wantRanges:   []string{"", "", ""},
+			wantWaits:    []time.Duration{time.Millisecond, 2 * time.Millisecond},
+		},
+		{
+			name:           "should not retry client errors",
+			responses:      []downloadResponse{notFound},
+			wantRequests:   1,
+			wantRanges:     []string{""},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "should fail with a network error after max retries",
+			responses:      []downloadResponse{closeConnection, closeConnection, closeConnection, closeConnection},
+			wantRequests:   4,
+			wantRanges:     []string{"", "", "", ""},
+			wantWaits:      []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond},
+			wantNetworkErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			mockDownloadRetry(t, func(d time.Duration) { waits = append(waits, d) })
+
+			var mu sync.Mutex
+			var ranges []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(content))
+					return
+				}
+				mu.Lock()
+				ranges = append(ranges, r.Header.Get("Range"))
+				resp := serveContent
+				if len(ranges) <= len(tt.responses) {
+					resp = tt.responses[len(ranges)-1]
+				}
+				mu.Unlock()
+
+				w.Header().Set("ETag", `"v1"`)
+				switch resp {
+				case dropConnection, closeConnection:
+					if resp == dropConnection {
+						w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+						w.Header().Set("Accept-Ranges", "bytes")
+						w.WriteHeader(http.StatusOK)
+						_, _ = w.Write(content[:len(content)/2])
+					}
+					conn, _, err := http.NewResponseController(w).Hijack()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					_ = conn.Close()
+				case serverError:
+					w.WriteHeader(http.StatusBadGateway)
+				case notFound:
+					w.WriteHeader(http.StatusNotFound)
+				case changedContent:
+					w.Header().Set("ETag", `"v2"`)
+					http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(changed))
+				case ignoreRange:
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write(content)
+				default:
+					http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(content))
+				}
+			}))
+			defer srv.Close()
+
+			f := newFetcher(Options{})
+			f.quiet = true
+			if !tt.noCache {
+				f.downloads = cache.New(t.TempDir())
+			}
+			path := filepath.Join(t.TempDir(), "tool")
+
+			err := f.downloadFile(path, srv.URL+"/tool", nil)
+
+			if tt.wantStatusCode != 0 || tt.wantNetworkErr {
+				dlErr, ok := errors.AsType[*DownloadError](err)
+				if !ok {
+					t.Fatalf("downloadFile() error = %v, want *DownloadError", err)
+				}
+				if dlErr.StatusCode != tt.wantStatusCode {
+					t.Errorf("DownloadError.StatusCode = %d, want %d", dlErr.StatusCode, tt.wantStatusCode)
+				}
+				if dlErr.NetworkError() != tt.wantNetworkErr {
+					t.Errorf("DownloadError.NetworkError() = %v, want %v", dlErr.NetworkError(), tt.wantNetworkErr)
+				}
+				if dlErr.Attempts != tt.wantRequests {
+					t.Errorf("DownloadError.Attempts = %d, want %d", dlErr.Attempts, tt.wantRequests)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("downloadFile() error = %v", err)
+				}
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := content
+				if tt.wantContent != nil {
+					want = tt.wantContent
+				}
+				if !bytes.Equal(b, want) {
+					t.Errorf("Expected downloaded content to match, got %d bytes", len(b))
+				}
+			}
+
+			mu.Lock()
+			requested := slices.Clone(ranges)
+			mu.Unlock()
+			if len(requested) != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", len(requested), tt.wantRequests)
+			}
+			if diff := cmp.Diff(tt.wantRanges, requested); diff != "" {
+				t.Errorf("ranges mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantWaits, waits); diff != "" {
+				t.Errorf("waits mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDownloadFileWithLockedPartial(t *testing.T) {
+	content := bytes.Repeat([]byte("toolbox"), 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "tool", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	f := newFetcher(Options{})
+	f.quiet = true
+	f.downloads = cache.New(t.TempDir())
+	// a concurrent download of the url holds the partial file
+	partial, unlock, err := f.downloads.PartialFile(srv.URL + "/tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := os.WriteFile(partial, []byte("concurrent"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "tool")
+	if err := f.downloadFile(path, srv.URL+"/tool", nil); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || !bytes.Equal(b, content) {
+		t.Errorf("Expected downloaded content to match, got %d bytes, %v", len(b), err)
+	}
+	if b, err := os.ReadFile(partial); err != nil || string(b) != "concurrent" {
+		t.Errorf("Expected the partial file of the concurrent download to be kept, got %q, %v", b, err)
+	}
+}
+
+func TestInitDownload(t *testing.T) {
+	retries := 1
+	tests := []struct {
+		name        string
+		download    *types.DownloadConfig
+		wantRetries int
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{
+			name:        "Defaults",
+			wantRetries: defaultDownloadRetries,
+			wantTimeout: defaultDownloadTimeout,
+		},
+		{
+			name:        "Configured",
+			download:    &types.DownloadConfig{Retries: &retries, Timeout: "1m"},
+			wantRetries: 1,
+			wantTimeout: time.Minute,
+		},
+		{
+			name:     "Invalid timeout",
+			download: &types.DownloadConfig{Timeout: "1 minute"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFetcher(Options{})
+			err := f.initDownload(&types.Toolbox{Download: tt.download})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("initDownload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if f.retries != tt.wantRetries {
+				t.Errorf("retries = %d, want %d", f.retries, tt.wantRetries)
+			}
+			if f.timeout != tt.wantTimeout {
+				t.Errorf("timeout = %s, want %s", f.timeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func mockDownloadRetry(t *testing.T, s func(time.Duration)) {
+	t.Helper()
+	origSleep, origBackoff := sleep, downloadRetryBackoff
+	sleep, downloadRetryBackoff = s, time.Millisecond
+	t.Cleanup(func() {
+		sleep, downloadRetryBackoff = origSleep, origBackoff
+	})
+}
@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const (
+	actionInstall  = "install"
+	actionUpgrade  = "upgrade"
+	actionSkip     = "skip"
+	actionNotFound = "not found"
+	// actionRateLimited the version of the tool could not be resolved, as the rate limit is exceeded.
+	actionRateLimited = "skipped (rate limited)"
+)
+
+// ErrUpdatesAvailable is returned by a dry-run, if tools would be installed or upgraded.
+var ErrUpdatesAvailable = errors.New("updates available")
+
+// planEntry the action a fetch would perform for a tool.
+type planEntry struct {
+	tool    string
+	action  string
+	current string
+	version string
+	assets  []string
+}
+
+// planner collects the actions of a dry-run.
+type planner struct {
+	mu      sync.Mutex
+	entries []planEntry
+}
+
+func (f *fetcher) planSkip(tool *types.Tool, current string) {
+	f.plan(planEntry{tool: tool.Name, action: actionSkip, current: current, version: tool.Version})
+}
+
+func (f *fetcher) planNotFound(tool *types.Tool, current string) {
+	f.plan(planEntry{tool: tool.Name, action: actionNotFound, current: current, version: tool.Version})
+}
+
+func (f *fetcher) planRateLimited(tool *types.Tool, current string) {
+	f.plan(planEntry{tool: tool.Name, action: actionRateLimited, current: current})
+}
+
+func (f *fetcher) planDownload(tool *types.Tool, current string, assets ...string) {
+	action := actionInstall
+	if current != "" {
+		action = actionUpgrade
+	}
+	f.log.Printf("📋 Would %s %s (%s)", action, tool.Version, strings.Join(assets, ", "))
+	f.plan(planEntry{tool: tool.Name, action: action, current: current, version: tool.Version, assets: assets})
+}
+
+func (f *fetcher) plan(e planEntry) {
+	if !f.opts.DryRun {
+		return
+	}
+	f.planned.mu.Lock()
+	defer f.planned.mu.Unlock()
+	f.planned.entries = append(f.planned.entries, e)
+}
+
+// planAssets plans the download of the matching release assets.
+func (f *fetcher) planAssets(tb *types.Toolbox, tool *types.Tool, assets []types.Asset, current string) {
+	var names []string
+	for _, name := range append([]string{tool.Name}, tool.Additional...) {
+		if matching := findMatching(tb, f.platform, name, assets); matching != nil && !slices.Contains(names, matching.Name) {
+			names = append(names, matching.Name)
+		}
+	}
+	if len(names) == 0 {
+		tool.CouldNotBeFound = true
+		f.log.Print("❌ Couldn't find a file here!\n")
+		f.planNotFound(tool, current)
+		return
+	}
+	f.planDownload(tool, current, names...)
+}
+
+// hasUpdates returns true if any tool would be installed or upgraded.
+func (p *planner) hasUpdates() bool {
+	return slices.ContainsFunc(p.entries, func(e planEntry) bool {
+		return e.action == actionInstall || e.action == actionUpgrade
+	})
+}
+
+func (p *planner) print(w io.Writer) error {
+	slices.SortFunc(p.entries, func(a, b planEntry) int {
+		return strings.Compare(a.tool, b.tool)
+	})
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TOOL\tACTION\tCURRENT\tVERSION\tASSETS")
+	for _, e := range p.entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.tool, e.action, e.current, e.version, strings.Join(e.assets, ", "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,159 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/github"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestPlanAssets(t *testing.T) {
+	assets := []types.Asset{
+		{Name: "tool-" + runtime.GOOS + "-" + runtime.GOARCH},
+		{Name: "helper-" + runtime.GOOS + "-" + runtime.GOARCH},
+		{Name: "tool-fakeos-" + runtime.GOARCH},
+	}
+	tests := []struct {
+		name        string
+		tool        *types.Tool
+		current     string
+		want        planEntry
+		wantUpdates bool
+	}{
+		{
+			name: "should plan an install",
+			tool: &types.Tool{Name: "tool", Version: "v1.0.0"},
+			want: planEntry{
+				tool:    "tool",
+				action:  actionInstall,
+				version: "v1.0.0",
+				assets:  []string{"tool-" + runtime.GOOS + "-" + runtime.GOARCH},
+			},
+			wantUpdates: true,
+		},
+		{
+			name:    "should plan an upgrade with additional tools",
+			tool:    &types.Tool{Name: "tool", Version: "v1.0.0", Additional: []string{"helper"}},
+			current: "v0.9.0",
+			want: planEntry{
+				tool:    "tool",
+				action:  actionUpgrade,
+				current: "v0.9.0",
+				version: "v1.0.0",
+				assets:  []string{"tool-" + runtime.GOOS + "-" + runtime.GOARCH, "helper-" + runtime.GOOS + "-" + runtime.GOARCH},
+			},
+			wantUpdates: true,
+		},
+		{
+			name:    "should plan not found",
+			tool:    &types.Tool{Name: "other", Version: "v1.0.0"},
+			current: "v0.9.0",
+			want:    planEntry{tool: "other", action: actionNotFound, current: "v0.9.0", version: "v1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fetcher{opts: Options{DryRun: true}, planned: &planner{}, log: log.Default()}
+			f.planAssets(nil, tt.tool, assets, tt.current)
+
+			if diff := cmp.Diff([]planEntry{tt.want}, f.planned.entries, cmp.AllowUnexported(planEntry{})); diff != "" {
+				t.Errorf("planAssets() mismatch (-want +got):\n%s", diff)
+			}
+			if f.planned.hasUpdates() != tt.wantUpdates {
+				t.Errorf("hasUpdates() = %v, want %v", f.planned.hasUpdates(), tt.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestPlannerPrint(t *testing.T) {
+	p := &planner{entries: []planEntry{
+		{tool: "xyz", action: actionSkip, current: "v1.0.0", version: "v1.0.0"},
+		{tool: "abc", action: actionUpgrade, current: "v1.0.0", version: "v1.1.0", assets: []string{"abc.tar.gz"}},
+	}}
+	var buf bytes.Buffer
+	if err := p.print(&buf); err != nil {
+		t.Fatalf("print() error = %v", err)
+	}
+
+	expected := `TOOL  ACTION   CURRENT  VERSION  ASSETS
+abc   upgrade  v1.0.0   v1.1.0   abc.tar.gz
+xyz   skip     v1.0.0   v1.0.0   
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("print() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDryRunPlansRateLimitedTools(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	pf := hostPlatform()
+	assetName := "tool-" + pf.goos + "-" + pf.goarch
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/example/limited/releases/latest" {
+			w.Header().Set("X-RateLimit-Limit", "60")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&types.GithubRelease{
+			TagName: "v1.0.0",
+			Assets:  []types.Asset{{Name: assetName, BrowserDownloadURL: "https://example.com/" + assetName}},
+		})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Target:    target,
+		GithubAPI: srv.URL,
+		Tools: map[string]*types.Tool{
+			"limited": {Github: "example/limited"},
+			"tool":    {Github: "example/tool"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{
+		Versions: map[string]string{"limited": "v0.1.0", "tool": "v1.0.0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFetcher(Options{DryRun: true})
+	f.checkToolboxVersion = false
+	err := f.Fetch(cfgFile)
+
+	if _, ok := errors.AsType[*github.RateLimitError](err); !ok {
+		t.Errorf("Fetch() error = %v, want *github.RateLimitError", err)
+	}
+	if errors.Is(err, ErrUpdatesAvailable) {
+		t.Errorf("Fetch() error = %v, want no %v", err, ErrUpdatesAvailable)
+	}
+	want := []planEntry{
+		{tool: "limited", action: actionRateLimited, current: "v0.1.0"},
+		{tool: "tool", action: actionSkip, current: "v1.0.0", version: "v1.0.0"},
+	}
+	if diff := cmp.Diff(want, f.planned.entries, cmp.AllowUnexported(planEntry{})); diff != "" {
+		t.Errorf("plan mismatch (-want +got):\n%s", diff)
+	}
+}
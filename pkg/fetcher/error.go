@@ -1,6 +1,10 @@
 package fetcher
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
 
 type validationError struct {
 	msg string
@@ -13,3 +17,22 @@ func (e *validationError) Error() string {
 func ValidationError(pattern string, args ...any) error {
 	return &validationError{msg: fmt.Sprintf(pattern, args...)}
 }
+
+// skipped collects the errors of tools that were skipped without aborting the fetch.
+type skipped struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (s *skipped) add(tool string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, fmt.Errorf("%s was skipped: %w", tool, err))
+}
+
+// err returns the joined errors of the skipped tools, nil if no tool was skipped.
+func (s *skipped) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
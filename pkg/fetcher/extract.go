@@ -0,0 +1,63 @@
+package fetcher
+
+import (
+	"path"
+	"slices"
+
+	"github.com/bakito/toolbox/pkg/extract"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// maxListedContents the max number of archive entries logged if the archive does not contain the tool.
+const maxListedContents = 50
+
+// initExtract applies the extract config, limits not configured fall back to the defaults of the extract package.
+func (f *fetcher) initExtract(tb *types.Toolbox) {
+	if tb.Extract == nil {
+		return
+	}
+	f.extractOptions.Limits = extract.Limits{
+		MaxTotalSize: tb.Extract.MaxSizeMB << 20,
+		MaxEntrySize: tb.Extract.MaxEntrySizeMB << 20,
+		MaxEntries:   tb.Extract.MaxEntries,
+	}
+}
+
+// archiveExtractOptions returns the extract options, that log to the logger of the tool.
+func (f *fetcher) archiveExtractOptions() extract.Options {
+	opts := f.extractOptions
+	opts.Log = f.log
+	return opts
+}
+
+// toolExtractOptions returns the extract options, that only extract the binaries of the tool.
+func (f *fetcher) toolExtractOptions(tool *types.Tool, toolName string) extract.Options {
+	opts := f.archiveExtractOptions()
+	names := append([]string{toolName}, tool.Additional...)
+	opts.Filter = func(name string) bool {
+		base := path.Base(name)
+		return slices.ContainsFunc(names, func(n string) bool {
+			return nameMatches(base, n, f.platform)
+		})
+	}
+	return opts
+}
+
+// logExtracted logs a summary of the extracted entries.
+func (f *fetcher) logExtracted(res *extract.Result) {
+	f.log.Printf("📦 Extracted %d files, %d dirs, %d symlinks and %d hardlinks",
+		res.Count(extract.TypeFile), res.Count(extract.TypeDir),
+		res.Count(extract.TypeSymlink), res.Count(extract.TypeHardlink))
+}
+
+// logContents logs the contents of an archive not containing the tool.
+func (f *fetcher) logContents(fileName string, res *extract.Result) {
+	f.log.Printf("🔍 No matching binary found in %s, it contains %d entries:", fileName, len(res.Contents))
+	for i, c := range res.Contents {
+		if i == maxListedContents {
+			f.log.Printf("\t... and %d more", len(res.Contents)-maxListedContents)
+			break
+		}
+		f.log.Printf("\t%s", c)
+	}
+}
@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/extract"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestInitExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		extract *types.ExtractConfig
+		want    extract.Limits
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name:    "Configured",
+			extract: &types.ExtractConfig{MaxSizeMB: 100, MaxEntrySizeMB: 10, MaxEntries: 50},
+			want:    extract.Limits{MaxTotalSize: 100 << 20, MaxEntrySize: 10 << 20, MaxEntries: 50},
+		},
+		{
+			name:    "Disabled",
+			extract: &types.ExtractConfig{MaxSizeMB: -1, MaxEntrySizeMB: -1, MaxEntries: -1},
+			want:    extract.Limits{MaxTotalSize: -1 << 20, MaxEntrySize: -1 << 20, MaxEntries: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFetcher(Options{})
+			f.initExtract(&types.Toolbox{Extract: tt.extract})
+			if diff := cmp.Diff(tt.want, f.extractOptions.Limits); diff != "" {
+				t.Errorf("Limits mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestToolExtractOptions(t *testing.T) {
+	f := newFetcher(Options{})
+	f.platform = platform{goos: "windows", goarch: "amd64"}
+	f.extractOptions.Limits.MaxEntries = 10
+	opts := f.toolExtractOptions(&types.Tool{Additional: []string{"kubens"}}, "kubectx")
+	if opts.Limits.MaxEntries != 10 {
+		t.Errorf("expected the configured limits to be kept, got %v", opts.Limits)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "kubectx.exe", want: true},
+		{name: "bin/kubectx", want: true},
+		{name: "kubectx_1.0.0/kubectx_windows_amd64.exe", want: true},
+		{name: "bin/kubens.exe", want: true},
+		{name: "bin/kubectx.sh"},
+		{name: "kubectx/LICENSE"},
+		{name: "completion/kubectx.bash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.Filter(tt.name); got != tt.want {
+				t.Errorf("Filter(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,18 +3,21 @@ package fetcher
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"os/user"
+	"path"
 	"path/filepath"
-	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"text/template"
 	"time"
@@ -25,8 +28,11 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/bakito/toolbox/pkg/arch"
+	"github.com/bakito/toolbox/pkg/cache"
 	"github.com/bakito/toolbox/pkg/extract"
 	"github.com/bakito/toolbox/pkg/github"
+	"github.com/bakito/toolbox/pkg/gitlab"
+	"github.com/bakito/toolbox/pkg/google"
 	"github.com/bakito/toolbox/pkg/http"
 	"github.com/bakito/toolbox/pkg/quietly"
 	"github.com/bakito/toolbox/pkg/types"
@@ -38,6 +44,9 @@ const (
 	toolboxDocConfigFile = ".config/toolbox.yaml"
 	toolboxVersionsFile  = ".toolbox-versions.yaml"
 	oldExecutablePrefix  = ".toolbox-old."
+
+	// lowRateLimit the number of remaining github API requests below which a warning is logged.
+	lowRateLimit = 10
 )
 
 var (
@@ -56,9 +65,47 @@ var (
 	excludedSuffixes = []string{"sum", "sha256", "sha512", "sbom", "pem", "sig", "rpm", "txt", "deb", "json", "asc", "apk"}
 )
 
-func New() Fetcher {
+// Options the fetcher options.
+type Options struct {
+	// Locked install the tools exactly as defined in the lock file.
+	Locked bool
+	// Parallel the number of tools processed concurrently.
+	Parallel int
+	// DryRun report what would be fetched, without downloading anything.
+	DryRun bool
+	// OS the operating system to fetch the tools for. Defaults to the current OS.
+	OS string
+	// Arch the architecture to fetch the tools for. Defaults to the current architecture.
+	Arch string
+	// Target overrides the target directory of the toolbox config.
+	Target string
+	// From install the tools from a bundle (directory or tarball) without any network calls.
+	From string
+	// NoCache disables the download cache.
+	NoCache bool
+	// Refresh bypasses the cached github API responses.
+	Refresh bool
+}
+
+func New(opts Options) Fetcher {
+	return newFetcher(opts)
+}
+
+func newFetcher(opts Options) *fetcher {
 	return &fetcher{
 		grabClient: grab.NewClient(),
+		opts:       opts,
+		platform:   newPlatform(opts.OS, opts.Arch),
+		recorded:   &recorder{tools: make(map[string]*types.LockedTool)},
+		planned:    &planner{},
+		skipped:    &skipped{},
+		retries:    defaultDownloadRetries,
+		timeout:    defaultDownloadTimeout,
+		targets:    &keyedMutex{locks: make(map[string]*sync.Mutex)},
+		log:        log.Default(),
+		out:        os.Stdout,
+
+		checkToolboxVersion: true,
 	}
 }
 
@@ -69,9 +116,40 @@ type fetcher struct {
 	executablePath string
 	upx            bool
 	grabClient     *grab.Client
+	opts           Options
+	platform       platform
+	recorded       *recorder
+	planned        *planner
+	skipped        *skipped
+	targets        *keyedMutex
+	// downloads the download cache, nil if disabled
+	downloads *cache.Cache
+	// responses the cache of the github API responses, nil if disabled
+	responses *cache.Cache
+	// responseTTL the duration cached github API responses are used without revalidation
+	responseTTL time.Duration
+	// retries the number of retries of failed downloads
+	retries int
+	// timeout the maximum duration of a download attempt, 0 for no timeout
+	timeout time.Duration
+	// extractOptions the options of the extraction of downloaded archives
+	extractOptions extract.Options
+	// log the logger of the tool currently processed
+	log *log.Logger
+	// out the output of the tool currently processed
+	out io.Writer
+	// quiet if enabled, no download progress is printed
+	quiet bool
+	// lockOnly if enabled, downloaded assets are only recorded in the lock and not installed
+	lockOnly bool
+	// checkToolboxVersion if enabled, fetch checks for a new toolbox version
+	checkToolboxVersion bool
 }
 
 func (f *fetcher) Fetch(cfgFile string, selectedTools ...string) error {
+	if err := f.checkPlatform(); err != nil {
+		return err
+	}
 	var err error
 	f.executablePath, err = os.Executable()
 	if err != nil {
@@ -80,35 +158,71 @@ func (f *fetcher) Fetch(cfgFile string, selectedTools ...string) error {
 
 	log.Printf("🧰 toolbox %s", version.Version)
 
+	tb, tbFile, err := ReadToolbox(cfgFile)
+	if err != nil {
+		return err
+	}
+
 	client := resty.New()
+	if err := f.configureNetwork(tb, client); err != nil {
+		return err
+	}
 
-	tbRel, err := github.LatestRelease(client, "bakito/toolbox", true)
-	if err != nil {
+	if err := f.initCache(tb); err != nil {
 		return err
 	}
-	if tbRel.TagName != version.Version {
-		log.Printf("🌟 A new toolbox version is available %s (current: %s)\n", tbRel.TagName, version.Version)
+
+	if f.resolvesVersions() && f.checkToolboxVersion {
+		api := withResponseCache(github.NewAPI(""), f.responses, f.responseTTL, f.opts.Refresh)
+		tbRel, err := api.LatestRelease(client, "bakito/toolbox", true)
+		if rle, ok := errors.AsType[*github.RateLimitError](err); ok {
+			log.Printf("⏳ Could not check for a new toolbox version: %v", rle)
+		} else if ne, ok := errors.AsType[*http.NetworkError](err); ok {
+			log.Printf("⚠️ Could not check for a new toolbox version: %v", ne)
+		} else if err != nil {
+			return err
+		} else if tbRel.TagName != version.Version {
+			log.Printf("🌟 A new toolbox version is available %s (current: %s)\n", tbRel.TagName, version.Version)
+		}
 	}
 
-	tb, _, err := ReadToolbox(cfgFile)
-	if tb.HasGithubTools() && !github.TokenSet() {
+	if tb.HasGithubTools() && !github.TokenSet() && f.resolvesVersions() {
 		log.Print("⚠️ when using github tools, defining a github token 'GITHUB_TOKEN' is recommended")
 	}
+	if tb.HasGitlabTools() && !gitlab.TokenSet() && f.resolvesVersions() {
+		log.Print("⚠️ when using gitlab tools, defining a gitlab token 'GITLAB_TOKEN' is recommended")
+	}
+	if f.opts.Target != "" {
+		tb.Target = f.opts.Target
+	}
+	sanitizeTargetDir(tb)
+
+	lockFile := lockFilePath(tbFile)
+	lock, err := readLock(lockFile)
 	if err != nil {
 		return err
 	}
-	sanitizeTargetDir(tb)
 
 	if tb.Upx {
 		f.checkUpxAvailable()
 	}
 
-	if err := f.assureTargetDirAvailable(tb); err != nil {
+	if err := f.initDownload(tb); err != nil {
 		return err
 	}
+	f.initExtract(tb)
 
-	if err := f.deleteOldBinary(tb); err != nil {
-		return err
+	if !f.opts.DryRun {
+		if err := f.assureTargetDirAvailable(tb); err != nil {
+			return err
+		}
+
+		if err := f.deleteOldBinary(tb); err != nil {
+			return err
+		}
+		if err := deleteOldTrees(tb); err != nil {
+			return err
+		}
 	}
 
 	if tb.Aliases != nil {
@@ -127,24 +241,132 @@ func (f *fetcher) Fetch(cfgFile string, selectedTools ...string) error {
 
 	defer func() { _ = os.RemoveAll(tmp) }()
 
+	var bundleDir string
+	var index *types.BundleIndex
+	if f.opts.From != "" {
+		if bundleDir, index, err = openBundle(f.opts.From, tmp); err != nil {
+			return err
+		}
+	}
+
 	tools := tb.GetTools()
-	fmt.Println()
+	var selected []*types.Tool
 	for _, tool := range tools {
 		if contains(selectedTools, tool.Name) {
-			if err := f.handleTool(client, ver, tmp, tb, tool); err != nil {
-				if _, ok := errors.AsType[*validationError](err); !ok {
-					return err
-				}
-				tool.Invalid = true
-			}
+			selected = append(selected, tool)
 		} else {
 			// keep current version
 			tool.Version = ver[tool.Name]
 		}
 	}
 
+	fmt.Println()
+	done := &completed{tools: make(map[string]bool)}
+	err = f.processTools(tb, selected, func(tf *fetcher, tool *types.Tool) error {
+		toolTmp := filepath.Join(tmp, tool.Name)
+		var err error
+		switch {
+		case f.opts.From != "":
+			err = tf.handleBundledTool(index, bundleDir, ver, tb, tool)
+		case f.opts.Locked:
+			err = tf.handleLockedTool(lock, ver, toolTmp, tb, tool)
+		default:
+			err = tf.handleTool(client, lock, ver, toolTmp, tb, tool)
+		}
+		if err == nil {
+			done.add(tool.Name)
+		}
+		return err
+	})
+	if err != nil {
+		return f.abort(err, tb, lock, lockFile, ver, selected, done)
+	}
+
+	if f.opts.DryRun {
+		if err := f.planned.print(os.Stdout); err != nil {
+			return err
+		}
+		// tools skipped by the rate limit might have updates as well
+		if f.planned.hasUpdates() {
+			return errors.Join(ErrUpdatesAvailable, f.skipped.err())
+		}
+		return f.skipped.err()
+	}
+
+	if err := f.saveState(tb, lock, lockFile); err != nil {
+		return err
+	}
+	if err := f.evictCache(tb); err != nil {
+		return err
+	}
+	return f.skipped.err()
+}
+
+// saveState saves the lock file, the versions file and the manifest of the target dir.
+func (f *fetcher) saveState(tb *types.Toolbox, lock *types.Lock, lockFile string) error {
+	if f.resolvesVersions() {
+		// save lock
+		if err := SaveYamlFile(lockFile, mergeLock(lock, f.recorded.tools, tb.GetTools(), f.platform.String())); err != nil {
+			return err
+		}
+	}
+
 	// save versions
-	return SaveYamlFile(filepath.Join(tb.Target, toolboxVersionsFile), tb.Versions())
+	if err := SaveYamlFile(filepath.Join(tb.Target, toolboxVersionsFile), tb.Versions()); err != nil {
+		return err
+	}
+	return updateManifest(tb, f.platform)
+}
+
+// abort saves the state of the tools processed before the fetch failed and prints a summary.
+// Selected tools that were not processed keep their current version.
+func (f *fetcher) abort(
+	err error,
+	tb *types.Toolbox,
+	lock *types.Lock,
+	lockFile string,
+	ver map[string]string,
+	selected []*types.Tool,
+	done *completed,
+) error {
+	var failed []string
+	for _, tool := range selected {
+		if done.tools[tool.Name] || tool.Invalid {
+			continue
+		}
+		failed = append(failed, tool.Name)
+		tool.Version = ver[tool.Name]
+		delete(f.recorded.tools, tool.Name)
+	}
+
+	log.Printf("❌ Fetch aborted: %v", err)
+	log.Printf("📋 %d of %d tools processed, not processed: %s",
+		len(selected)-len(failed), len(selected), strings.Join(failed, ", "))
+
+	if f.opts.DryRun {
+		return err
+	}
+	if saveErr := f.saveState(tb, lock, lockFile); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// resolvesVersions returns true if the versions of the tools are resolved online.
+func (f *fetcher) resolvesVersions() bool {
+	return !f.opts.Locked && f.opts.From == ""
+}
+
+// checkPlatform validates the platform the tools are fetched for. Tools of another platform than the host
+// require a separate target, to not overwrite the binaries and versions of the host platform.
+func (f *fetcher) checkPlatform() error {
+	if err := f.platform.validate(); err != nil {
+		return err
+	}
+	if !f.platform.isHost() && f.opts.Target == "" && !f.opts.DryRun {
+		return fmt.Errorf("tools for %s must be fetched into a separate dir, define it with --target", f.platform)
+	}
+	return nil
 }
 
 func sanitizeTargetDir(tb *types.Toolbox) {
@@ -174,6 +396,10 @@ func (*fetcher) assureTargetDirAvailable(tb *types.Toolbox) error {
 
 func (*fetcher) deleteOldBinary(tb *types.Toolbox) error {
 	return filepath.Walk(tb.Target, func(_ string, f os.FileInfo, _ error) error {
+		if f.IsDir() && f.Name() == treeDir {
+			// the trees are cleaned up by deleteOldTrees
+			return filepath.SkipDir
+		}
 		if !f.IsDir() {
 			if strings.HasPrefix(f.Name(), oldExecutablePrefix) {
 				toolPath := filepath.Join(tb.Target, f.Name())
@@ -209,57 +435,194 @@ func SaveYamlFile(path string, obj any) error {
 
 func (f *fetcher) handleTool(
 	client *resty.Client,
+	lock *types.Lock,
 	ver map[string]string,
 	tmp string,
 	tb *types.Toolbox,
 	tool *types.Tool,
 ) error {
-	log.Printf("🛠  Processing %s\n", tool.Name)
-	defer fmt.Println()
-	var ghr *types.GithubRelease
-	var err error
+	f.log.Printf("🛠  Processing %s\n", tool.Name)
+	defer fmt.Fprintln(f.out)
 	configVersion := tool.Version
 	currentVersion := ver[tool.Name]
+	rel, err := f.resolveVersion(client, tb, tool, currentVersion)
+	if rle, ok := errors.AsType[*github.RateLimitError](err); ok {
+		// keep the current version and continue with the other tools
+		f.log.Printf("⏳ Skipping %s: %v", tool.Name, rle)
+		tool.Version = currentVersion
+		f.skipped.add(tool.Name, rle)
+		f.planRateLimited(tool, currentVersion)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isNewer(currentVersion, tool.Version) {
+		f.log.Print("✅ Skipping since newer version is installed\n")
+		f.planSkip(tool, currentVersion)
+		return nil
+	}
+
+	if tool.Version == currentVersion {
+		if configVersion != "" && !strings.HasPrefix(configVersion, "http") {
+			f.log.Printf("✅ Skipping since already configured version %s\n", configVersion)
+		} else {
+			f.log.Print("✅ Skipping since already latest version\n")
+		}
+		f.planSkip(tool, currentVersion)
+		if !f.opts.DryRun && needsLock(lock, tool, f.platform) {
+			f.lockInstalled(client, tb, tool, rel, tmp, currentVersion)
+		}
+		return nil
+	}
+
+	return f.download(client, tb, tool, rel, tmp, currentVersion)
+}
+
+// download downloads the assets of the resolved release of the tool.
+func (f *fetcher) download(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	rel *release,
+	tmp, currentVersion string,
+) error {
+	switch tool.Source() {
+	case types.SourceDownloadURL:
+		return f.downloadFromURL(tb, tmp, tool, currentVersion)
+	case "":
+		return nil
+	}
+
+	assets, err := rel.assets(client, tool)
+	if err != nil {
+		return err
+	}
+
+	if f.opts.DryRun {
+		f.planAssets(tb, tool, assets, currentVersion)
+		return nil
+	}
+	return f.downloadAssets(client, tb, tool, assets, tmp)
+}
+
+// release the resolved release of a tool.
+type release struct {
+	github *types.GithubRelease
+	gitlab *types.GitlabRelease
+}
+
+// assets returns the assets of the release.
+func (r *release) assets(client *resty.Client, tool *types.Tool) ([]types.Asset, error) {
+	switch {
+	case r.github != nil:
+		return r.github.Assets, nil
+	case r.gitlab != nil:
+		return r.gitlab.GetAssets(), nil
+	case tool.Google != "":
+		return google.Assets(client, tool.Google, tool.Version)
+	default:
+		return nil, nil
+	}
+}
+
+// resolveVersion resolves the version of the tool to be fetched and sets it as tool version.
+func (f *fetcher) resolveVersion(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	currentVersion string,
+) (*release, error) {
+	rel := &release{}
+	var err error
+	configVersion := tool.Version
 	if tool.Github != "" {
+		api := f.cachedGithubAPI(tb, tool)
 		if configVersion == "" {
-			ghr, err = github.LatestRelease(client, tool.Github, false)
+			rel.github, err = api.LatestRelease(client, tool.Github, f.quiet)
 		} else {
-			ghr, err = github.Release(client, tool.Github, configVersion, false)
+			rel.github, err = api.Release(client, tool.Github, configVersion, f.quiet)
 		}
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if rl := api.RateLimit(); rl != nil && rl.Remaining < lowRateLimit {
+			f.log.Printf("⚠️ github API rate limit: %s", rl)
 		}
 
 		if tool.Version == "" {
-			tool.Version = ghr.TagName
-			if currentVersion != "" && tool.Version != currentVersion {
-				log.Printf("Latest Version: %s (current: %s)", tool.Version, currentVersion)
-			} else {
-				log.Printf("Latest Version: %s", tool.Version)
-			}
+			tool.Version = rel.github.TagName
+			f.logLatestVersion(tool.Version, currentVersion)
+		}
+	} else if tool.Gitlab != "" {
+		api := gitlab.NewAPI(tool.GitlabURL).WithLogger(f.log)
+		if configVersion == "" {
+			rel.gitlab, err = api.LatestRelease(client, tool.Gitlab, f.quiet)
+		} else {
+			rel.gitlab, err = api.Release(client, tool.Gitlab, configVersion, f.quiet)
+		}
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if isNewer(currentVersion, tool.Version) {
-		log.Print("✅ Skipping since newer version is installed\n")
-		return nil
+		if tool.Version == "" {
+			tool.Version = rel.gitlab.TagName
+			f.logLatestVersion(tool.Version, currentVersion)
+		}
+	} else if tool.Google != "" && tool.DownloadURL == "" {
+		if configVersion == "" {
+			tool.Version, err = google.LatestVersion(client, tool.Google)
+		} else if strings.HasPrefix(configVersion, "http") {
+			tool.Version, err = fetchVersion(client, tool, configVersion)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tool.Version != configVersion {
+			f.logLatestVersion(tool.Version, currentVersion)
+		}
+	} else if strings.HasPrefix(configVersion, "http") {
+		if tool.Version, err = fetchVersion(client, tool, configVersion); err != nil {
+			return nil, err
+		}
+		f.logLatestVersion(tool.Version, currentVersion)
 	}
+	return rel, nil
+}
 
-	if tool.Version == currentVersion {
-		if configVersion != "" {
-			log.Printf("✅ Skipping since already configured version %s\n", configVersion)
-		} else {
-			log.Print("✅ Skipping since already latest version\n")
-		}
-		return nil
+// githubAPI returns the github API of the tool.
+// The API URL is taken from the tool, the toolbox config or the env variable 'TOOLBOX_GITHUB_API'.
+func githubAPI(tb *types.Toolbox, tool *types.Tool) *github.API {
+	return github.NewAPI(cmp.Or(tool.GithubAPI, tb.GithubAPI, os.Getenv(github.EnvGithubAPI)))
+}
+
+func (f *fetcher) logLatestVersion(latestVersion, currentVersion string) {
+	if currentVersion != "" && latestVersion != currentVersion {
+		f.log.Printf("Latest Version: %s (current: %s)", latestVersion, currentVersion)
+	} else {
+		f.log.Printf("Latest Version: %s", latestVersion)
 	}
+}
 
-	if tool.DownloadURL != "" {
-		return f.downloadFromURL(client, tb, ver, tmp, tool)
-	} else if ghr != nil {
-		return f.downloadViaGithub(tb, tool, ghr, tmp)
+// fetchVersion reads the version from the given URL, authenticated with the auth of the tool.
+func fetchVersion(client *resty.Client, tool *types.Tool, url string) (string, error) {
+	auth, err := authorization(tool, url)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	req := client.R().EnableTrace()
+	if auth != "" {
+		req.SetHeader("Authorization", auth)
+	}
+	resp, err := req.Get(url)
+	if err != nil {
+		return "", http.CheckError(err)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("version request was not successful: %s (%d)", url, resp.StatusCode())
+	}
+	return strings.TrimSpace(string(resp.Body())), nil
 }
 
 func isNewer(toolVersion, currentVersion string) bool {
@@ -269,87 +632,99 @@ func isNewer(toolVersion, currentVersion string) bool {
 	return semver.Compare(toolVersion, currentVersion) > 0
 }
 
-func (f *fetcher) downloadViaGithub(tb *types.Toolbox, tool *types.Tool, ghr *types.GithubRelease, tmp string) error {
-	matching := findMatching(tb, tool.Name, ghr.Assets)
+func (f *fetcher) downloadAssets(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	assets []types.Asset,
+	tmp string,
+) error {
+	matching := findMatching(tb, f.platform, tool.Name, assets)
 	tool.CouldNotBeFound = true
 	if matching != nil {
 		tool.CouldNotBeFound = false
-		if err := f.fetchTool(tool, tool.Name, matching.BrowserDownloadURL, tmp, tb.Target); err != nil {
+		if err := f.fetchAsset(client, tool, tool.Name, matching, assets, tmp, tb.Target); err != nil {
 			return err
 		}
 	}
-	for _, add := range tool.Additional {
-		matching := findMatching(tb, add, ghr.Assets)
+	additional := tool.Additional
+	if tool.TreeInstall() {
+		// the additional binaries of a tree are part of the archive of the tool
+		additional = nil
+	}
+	for _, add := range additional {
+		matching := findMatching(tb, f.platform, add, assets)
 		if matching != nil {
 			tool.CouldNotBeFound = false
-			if err := f.fetchTool(tool, add, matching.BrowserDownloadURL, tmp, tb.Target); err != nil {
+			if err := f.fetchAsset(client, tool, add, matching, assets, tmp, tb.Target); err != nil {
 				return err
 			}
 		}
 	}
 	if tool.CouldNotBeFound {
-		log.Print("❌ Couldn't find a file here!\n")
+		f.log.Print("❌ Couldn't find a file here!\n")
 	}
 	return nil
 }
 
-func (f *fetcher) downloadFromURL(
+func (f *fetcher) fetchAsset(
 	client *resty.Client,
-	tb *types.Toolbox,
-	ver map[string]string,
-	tmp string,
 	tool *types.Tool,
+	toolName string,
+	asset *types.Asset,
+	assets []types.Asset,
+	tmp, targetDir string,
 ) error {
-	currentVersion := ver[tool.Name]
-	if strings.HasPrefix(tool.Version, "http") {
-		resp, err := client.R().
-			EnableTrace().
-			Get(tool.Version)
-		if err != nil {
-			return err
-		}
-		tool.Version = string(resp.Body())
-
-		if currentVersion != "" && tool.Version != currentVersion {
-			log.Printf("Latest Version: %s (current: %s)", tool.Version, currentVersion)
-		} else {
-			log.Printf("Latest Version: %s", tool.Version)
-		}
+	checksum, err := f.lookupChecksum(client, asset, assets)
+	if err != nil {
+		return err
 	}
+	if checksum == "" {
+		f.log.Printf("⚠️ No checksum found for %s", asset.Name)
+	}
+	return f.fetchTool(tool, toolName, asset.BrowserDownloadURL, checksum, tmp, targetDir)
+}
 
-	if tool.Version == currentVersion {
-		log.Print("✅ Skipping since already latest version\n")
+func (f *fetcher) downloadFromURL(tb *types.Toolbox, tmp string, tool *types.Tool, currentVersion string) error {
+	url := parseTemplate(tool.DownloadURL, tool.Version, f.platform)
+	if f.opts.DryRun {
+		f.planDownload(tool, currentVersion, url)
 		return nil
 	}
-	return f.fetchTool(tool, tool.Name, parseTemplate(tool.DownloadURL, tool.Version), tmp, tb.Target)
+	return f.fetchTool(tool, tool.Name, url, tool.Checksum, tmp, tb.Target)
 }
 
-func findMatching(tb *types.Toolbox, toolName string, assets []types.Asset) *types.Asset {
+func findMatching(tb *types.Toolbox, pf platform, toolName string, assets []types.Asset) *types.Asset {
 	var matching []*types.Asset
 	for i := range assets {
 		a := assets[i]
 		if strings.Contains(a.Name, toolName) &&
-			matches(runtime.GOOS, a.Name) &&
+			matches(pf.goos, a.Name) &&
 			!hasForbiddenSuffix(tb, a) {
 			matching = append(matching, &a)
 		}
 	}
 
 	slices.SortFunc(matching, func(a, b *types.Asset) int {
-		mi := strings.HasPrefix(a.Name, toolName+"-")
-		mj := strings.HasPrefix(b.Name, toolName+"-")
+		// prefer assets in an os/arch directory named like the binary
+		mi := path.Base(a.Name) == pf.binaryName(toolName)
+		mj := path.Base(b.Name) == pf.binaryName(toolName)
 
 		if mi == mj {
-			mi = isExactMatch(runtime.GOARCH, a.Name)
-			mj = isExactMatch(runtime.GOARCH, b.Name)
+			mi = strings.HasPrefix(a.Name, toolName+"-")
+			mj = strings.HasPrefix(b.Name, toolName+"-")
 		}
 		if mi == mj {
-			mi = matches(runtime.GOARCH, a.Name)
-			mj = matches(runtime.GOARCH, b.Name)
+			mi = isExactMatch(pf.goarch, a.Name)
+			mj = isExactMatch(pf.goarch, b.Name)
 		}
 		if mi == mj {
-			mi = strings.Contains(a.Name, runtime.GOARCH)
-			mj = strings.Contains(b.Name, runtime.GOARCH)
+			mi = matches(pf.goarch, a.Name)
+			mj = matches(pf.goarch, b.Name)
+		}
+		if mi == mj {
+			mi = strings.Contains(a.Name, pf.goarch)
+			mj = strings.Contains(b.Name, pf.goarch)
 		}
 		if mi == mj {
 			// prefer non archive files
@@ -358,8 +733,8 @@ func findMatching(tb *types.Toolbox, toolName string, assets []types.Asset) *typ
 		}
 		if mi == mj {
 			// prefer non archive files
-			mi = strings.HasSuffix(a.Name, defaultFileExtension())
-			mj = strings.HasSuffix(b.Name, defaultFileExtension())
+			mi = strings.HasSuffix(a.Name, pf.fileExtension())
+			mj = strings.HasSuffix(b.Name, pf.fileExtension())
 		}
 		if mi == mj {
 			return extensionWeight(b.Name) - extensionWeight(a.Name)
@@ -394,31 +769,31 @@ func hasForbiddenSuffix(tb *types.Toolbox, a types.Asset) bool {
 	return false
 }
 
-func parseTemplate(templ, v string) string {
+func parseTemplate(templ, v string, pf platform) string {
 	ut, err := template.New("url").Parse(templ)
 	if err != nil {
 		panic(err)
 	}
 
 	var b bytes.Buffer
-	if err := ut.Execute(&b, templateData(v)); err != nil {
+	if err := ut.Execute(&b, templateData(v, pf)); err != nil {
 		panic(err)
 	}
 	return b.String()
 }
 
-func templateData(v string) map[string]string {
+func templateData(v string, pf platform) map[string]string {
 	return map[string]string{
 		"Version":    v,
 		"VersionNum": strings.TrimPrefix(v, "v"),
-		"OS":         runtime.GOOS,
-		"Arch":       runtime.GOARCH,
-		"ArchBIT":    strconv.Itoa(strconv.IntSize),
-		"FileExt":    defaultFileExtension(),
+		"OS":         pf.goos,
+		"Arch":       pf.goarch,
+		"ArchBIT":    pf.archBits(),
+		"FileExt":    pf.fileExtension(),
 	}
 }
 
-func (f *fetcher) fetchTool(tool *types.Tool, toolName, url, tmpDir, targetDir string) error {
+func (f *fetcher) fetchTool(tool *types.Tool, toolName, url, checksum, tmpDir, targetDir string) error {
 	dir := filepath.Join(tmpDir, toolName)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
@@ -426,16 +801,51 @@ func (f *fetcher) fetchTool(tool *types.Tool, toolName, url, tmpDir, targetDir s
 	paths := strings.Split(url, "/")
 	fileName := paths[len(paths)-1]
 	path := filepath.Join(dir, fileName)
-	log.Printf("📥 Downloading %s", url)
-	if err := f.downloadFile(path, url); err != nil {
+	cached := f.fromCache(path, url, tool.Version, checksum)
+	if !cached {
+		header, err := downloadHeader(tool, url)
+		if err != nil {
+			return err
+		}
+		f.log.Printf("📥 Downloading %s", url)
+		if err := f.downloadFile(path, url, header); err != nil {
+			return err
+		}
+	}
+	if checksum != "" {
+		if err := verifyChecksum(path, checksum); err != nil {
+			f.log.Printf("🔏🚫 %v", err)
+			return err
+		}
+		f.log.Print("🔏 Checksum matches")
+	}
+	if !cached {
+		f.toCache(url, tool.Version, checksum, path)
+	}
+	if err := f.recordLock(tool, toolName, url, path); err != nil {
 		return err
 	}
-	extracted, err := extract.File(path, dir)
+	if f.lockOnly {
+		return nil
+	}
+	switch tool.InstallMode {
+	case "", types.InstallModeBinary:
+	case types.InstallModeTree:
+		return f.installTree(tool, path, fileName, targetDir)
+	default:
+		return fmt.Errorf("invalid installMode %q of tool %s", tool.InstallMode, tool.Name)
+	}
+	extracted, err := extract.File(path, dir, f.toolExtractOptions(tool, toolName))
 	if err != nil {
-		return err
+		return f.extractionError(fileName, err)
 	}
 	downloadedName := toolName
-	if !extracted {
+	if extracted.Archive {
+		if len(extracted.Entries) == 0 {
+			f.logContents(fileName, extracted)
+		}
+		f.logExtracted(extracted)
+	} else {
 		downloadedName = fileName
 	}
 	if err := f.moveToTarget(tool, toolName, targetDir, dir, downloadedName, false); err != nil {
@@ -453,27 +863,54 @@ func (f *fetcher) fetchTool(tool *types.Tool, toolName, url, tmpDir, targetDir s
 	return nil
 }
 
-func (*fetcher) validate(targetPath, check string) error {
-	match, err := arch.DoesBinaryMatchCurrentOSArch(targetPath)
+func (f *fetcher) validate(targetPath, check string) error {
+	if err := f.checkArch(targetPath); err != nil {
+		return err
+	}
+	return f.runCheck(targetPath, check)
+}
+
+// checkArch checks the binary is built for the platform.
+func (f *fetcher) checkArch(targetPath string) error {
+	match, err := arch.DoesBinaryMatchOSArch(targetPath, f.platform.goos, f.platform.goarch)
 	if err != nil {
-		log.Printf("📐🚫 Arch check failed: %v", err)
+		f.log.Printf("📐🚫 Arch check failed: %v", err)
 		return ValidationError("arch check failed %v", err)
 	}
 	if !match {
-		log.Print("📐🚫 Arch doesn't match system")
-		return ValidationError("arch doesn't match system")
+		f.log.Printf("📐🚫 Arch doesn't match %s", f.platform)
+		return ValidationError("arch doesn't match %s", f.platform)
 	}
-	log.Print("📐 Arch matches")
+	f.log.Print("📐 Arch matches")
+	return nil
+}
 
-	if check != "" {
-		// #nosec G204:
-		cmd := exec.CommandContext(context.TODO(), targetPath, strings.Fields(check)...)
-		if _, err := cmd.Output(); err != nil {
-			log.Printf("🚫 Check failed ('%s %s'): %v", targetPath, check, err)
-			return ValidationError("check failed %v", err)
-		}
-		log.Printf("👍 Check successful ('%s %s')", targetPath, check)
+// extractionError returns a validation error if the archive was rejected by exceeding a limit or containing
+// unsafe entries, to only mark the tool as invalid. Other errors are returned unchanged.
+func (f *fetcher) extractionError(fileName string, err error) error {
+	if _, ok := errors.AsType[*extract.LimitError](err); ok || errors.Is(err, extract.ErrUnsafeEntry) {
+		f.log.Printf("📦🚫 Archive %s rejected: %v", fileName, err)
+		return ValidationError("could not extract %s: %v", fileName, err)
 	}
+	return err
+}
+
+// runCheck runs the binary with the check args, if the binary can be executed on this system.
+func (f *fetcher) runCheck(targetPath, check string) error {
+	if check == "" {
+		return nil
+	}
+	if !f.platform.isHost() {
+		f.log.Printf("⏭️ Skipping check, %s binaries can not be executed on this system", f.platform)
+		return nil
+	}
+	// #nosec G204:
+	cmd := exec.CommandContext(context.TODO(), targetPath, strings.Fields(check)...)
+	if _, err := cmd.Output(); err != nil {
+		f.log.Printf("🚫 Check failed ('%s %s'): %v", targetPath, check, err)
+		return ValidationError("check failed %v", err)
+	}
+	f.log.Printf("👍 Check successful ('%s %s')", targetPath, check)
 	return nil
 }
 
@@ -485,18 +922,19 @@ func (f *fetcher) moveToTarget(
 	downloadedName string,
 	isAdditional bool,
 ) error {
-	trueBinaryName := binaryName(trueToolName)
+	trueBinaryName := f.platform.binaryName(trueToolName)
 	targetFilePath, err := filepath.Abs(filepath.Join(targetDir, trueBinaryName))
 	if err != nil {
 		return err
 	}
+	defer f.targets.lock(targetFilePath)()
 	if f.executablePath == targetFilePath {
 		renameTo := filepath.Join(targetDir, oldExecutablePrefix+trueBinaryName)
 		err = os.Rename(targetFilePath, renameTo)
 		if err != nil {
 			return err
 		}
-		log.Printf("🔀 Rename current executable to %s", renameTo)
+		f.log.Printf("🔀 Rename current executable to %s", renameTo)
 	}
 	ok, err := f.copyTool(tool, dir, downloadedName, targetDir, trueToolName)
 	if err != nil {
@@ -505,7 +943,7 @@ func (f *fetcher) moveToTarget(
 			if renameErr := os.Rename(targetFilePath, renameTo); renameErr != nil {
 				return err
 			}
-			log.Printf("🔀 Rename busy executable to %s", renameTo)
+			f.log.Printf("🔀 Rename busy executable to %s", renameTo)
 			ok, err = f.copyTool(tool, dir, downloadedName, targetDir, trueToolName)
 		}
 		if err != nil {
@@ -533,11 +971,11 @@ func (f *fetcher) copyTool(
 	for _, file := range files {
 		if file.IsDir() {
 			dirs = append(dirs, file)
-		} else if fileMatches(file, fileName) {
+		} else if nameMatches(file.Name(), fileName, f.platform) {
 			sourcePath := filepath.Join(dir, file.Name())
-			targetPath := filepath.Join(targetDir, binaryName(targetName))
+			targetPath := filepath.Join(targetDir, f.platform.binaryName(targetName))
 
-			if err := copyFile(sourcePath, targetPath); err != nil {
+			if err := f.copyFile(sourcePath, targetPath); err != nil {
 				return false, err
 			}
 			if err := f.validate(targetPath, tool.Check); err != nil {
@@ -546,7 +984,7 @@ func (f *fetcher) copyTool(
 
 			if f.upx {
 				if tool.SkipUpx {
-					log.Print("⏭️️ Skipping upx compression")
+					f.log.Print("⏭️️ Skipping upx compression")
 				} else {
 					f.upxCompress(targetPath)
 				}
@@ -564,31 +1002,32 @@ func (f *fetcher) copyTool(
 	return false, nil
 }
 
-func (*fetcher) upxCompress(targetPath string) {
-	log.Print("🗜️ Compressing with upx")
+func (f *fetcher) upxCompress(targetPath string) {
+	f.log.Print("🗜️ Compressing with upx")
 	cmd := exec.CommandContext(context.TODO(), "upx", "-q", "-q", targetPath)
 	stdout, err := cmd.Output()
 	if err == nil {
 		parts := strings.Fields(string(stdout))
 		size, _ := strconv.Atoi(parts[2])
-		log.Printf("\tCompressed to %s (%s)", parts[3], formatBytes(int64(size)))
+		f.log.Printf("\tCompressed to %s (%s)", parts[3], FormatBytes(int64(size)))
 	} else {
 		if ee, ok := errors.AsType[*exec.ExitError](err); ok && ee.ExitCode() == 2 {
-			log.Print("\tAlready Compressed")
+			f.log.Print("\tAlready Compressed")
 		} else {
-			log.Printf("\tCompression error: %v", err)
+			f.log.Printf("\tCompression error: %v", err)
 		}
 	}
 }
 
-func fileMatches(file os.DirEntry, fileName string) bool {
-	return file.Name() == binaryName(fileName) ||
-		file.Name() == fileName ||
-		file.Name() == binaryName(fmt.Sprintf("%s_%s_%s", fileName, runtime.GOOS, runtime.GOARCH)) ||
-		file.Name() == binaryName(fmt.Sprintf("%s-%s_%s", fileName, runtime.GOOS, runtime.GOARCH))
+// nameMatches returns true if the file name is the binary fileName, optionally suffixed with the platform.
+func nameMatches(name, fileName string, pf platform) bool {
+	return name == pf.binaryName(fileName) ||
+		name == fileName ||
+		name == pf.binaryName(fmt.Sprintf("%s_%s_%s", fileName, pf.goos, pf.goarch)) ||
+		name == pf.binaryName(fmt.Sprintf("%s-%s_%s", fileName, pf.goos, pf.goarch))
 }
 
-func copyFile(sourcePath, targetPath string) error {
+func (f *fetcher) copyFile(sourcePath, targetPath string) error {
 	from, err := os.Open(sourcePath)
 	if err != nil {
 		return err
@@ -605,7 +1044,7 @@ func copyFile(sourcePath, targetPath string) error {
 		return err
 	}
 	defer quietly.Close(to)
-	log.Printf("Copy %s to %s (%v)", from.Name(), to.Name(), formatBytes(fromStat.Size()))
+	f.log.Printf("Copy %s to %s (%v)", from.Name(), to.Name(), FormatBytes(fromStat.Size()))
 	_, err = to.ReadFrom(from)
 	return err
 }
@@ -709,48 +1148,23 @@ func extensionWeight(name string) int {
 	if strings.HasSuffix(ln, ".zip") {
 		return 8
 	}
-	return 0
-}
-
-func (f *fetcher) downloadFile(path, url string) (err error) {
-	req, err := grab.NewRequest(path, url)
-	if err != nil {
-		return err
+	if strings.HasSuffix(ln, ".tar.zst") || strings.HasSuffix(ln, ".tzst") {
+		return 7
 	}
-	req.HTTPRequest.Header.Set("User-Agent", "toolbox/"+version.Version)
-
-	resp := f.grabClient.Do(req)
-
-	t := time.NewTicker(200 * time.Millisecond)
-	defer t.Stop()
-
-Loop:
-	for {
-		select {
-		case <-t.C:
-			fmt.Printf("\r  %s / %s (%.2f%%) %s/s            ",
-				formatBytes(resp.BytesComplete()),
-				formatBytes(resp.Size()),
-				100*resp.Progress(),
-				formatBytes(int64(resp.BytesPerSecond())))
-
-		case <-resp.Done:
-			fmt.Printf("\r  %s / %s (%.2f%%) %s/s            ",
-				formatBytes(resp.BytesComplete()),
-				formatBytes(resp.Size()),
-				100*resp.Progress(),
-				formatBytes(int64(resp.BytesPerSecond())))
-			fmt.Println()
-			break Loop
-		}
+	if strings.HasSuffix(ln, ".tar.bz2") || strings.HasSuffix(ln, ".tbz2") || strings.HasSuffix(ln, ".tbz") {
+		return 6
 	}
-
-	if resp.Err() != nil {
-		return http.CheckError(resp.Err())
+	if strings.HasSuffix(ln, ".7z") {
+		return 5
 	}
-
-	log.Printf("Download saved to %s", resp.Filename)
-	return nil
+	if strings.HasSuffix(ln, ".tar") {
+		return 4
+	}
+	if strings.HasSuffix(ln, ".gz") || strings.HasSuffix(ln, ".xz") ||
+		strings.HasSuffix(ln, ".zst") || strings.HasSuffix(ln, ".bz2") {
+		return 3
+	}
+	return 0
 }
 
 func contains(list []string, v string) bool {
@@ -760,7 +1174,8 @@ func contains(list []string, v string) bool {
 	return len(list) == 0
 }
 
-func formatBytes(b int64) string {
+// FormatBytes formats the size in bytes in a human readable form.
+func FormatBytes(b int64) string {
 	const unit = 1024
 	if b < unit {
 		return fmt.Sprintf("%d B", b)
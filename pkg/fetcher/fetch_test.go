@@ -1,9 +1,24 @@
 package fetcher
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/go-resty/resty/v2"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/github"
+	pkghttp "github.com/bakito/toolbox/pkg/http"
 	"github.com/bakito/toolbox/pkg/types"
 )
 
@@ -256,7 +271,7 @@ func TestFindMatching(t *testing.T) {
 				case "amd64":
 					return &types.Asset{Name: "fnox-x86_64-unknown-linux-gnu.tar.gz"}
 				}
-				return findMatching(nil, "fnox", []types.Asset{
+				return findMatching(nil, hostPlatform(), "fnox", []types.Asset{
 					{Name: "fnox-aarch64-unknown-linux-gnu.tar.gz"},
 					{Name: "fnox-x86_64-unknown-linux-gnu.tar.gz"},
 				})
@@ -277,7 +292,7 @@ func TestFindMatching(t *testing.T) {
 				case "loong64":
 					return &types.Asset{Name: "nu-0.111.0-loongarch64-unknown-linux-gnu.tar.gz"}
 				}
-				return findMatching(nil, "nu", []types.Asset{
+				return findMatching(nil, hostPlatform(), "nu", []types.Asset{
 					{Name: "nu-0.111.0-loongarch64-unknown-linux-gnu.tar.gz"},
 					{Name: "nu-0.111.0-x86_64-unknown-linux-gnu.tar.gz"},
 				})
@@ -338,6 +353,17 @@ func TestFindMatching(t *testing.T) {
 			},
 			expected: &types.Asset{Name: "k9s_Linux_amd64.tar.gz"},
 		},
+		{
+			name:     "Prefer binary in os/arch directory",
+			tb:       nil,
+			toolName: "kubectl",
+			assets: []types.Asset{
+				{Name: "bin/" + runtime.GOOS + "/" + runtime.GOARCH + "/kubectl-convert"},
+				{Name: "bin/" + runtime.GOOS + "/" + runtime.GOARCH + "/" + binaryName("kubectl")},
+				{Name: "bin/" + runtime.GOOS + "/" + runtime.GOARCH + "/" + binaryName("kubectl") + ".sha256"},
+			},
+			expected: &types.Asset{Name: "bin/" + runtime.GOOS + "/" + runtime.GOARCH + "/" + binaryName("kubectl")},
+		},
 		{
 			name:     "Should prefer tar.gz over zip on Linux",
 			tb:       nil,
@@ -352,7 +378,7 @@ func TestFindMatching(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual := findMatching(tt.tb, tt.toolName, tt.assets)
+			actual := findMatching(tt.tb, hostPlatform(), tt.toolName, tt.assets)
 			if (actual == nil && tt.expected != nil) || (actual != nil && tt.expected == nil) {
 				t.Errorf("Expected: %v, but got: %v", tt.expected, actual)
 			} else if actual != nil && tt.expected != nil && actual.Name != tt.expected.Name {
@@ -361,3 +387,262 @@ func TestFindMatching(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleToolSkipsRateLimitedTool(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	f := newFetcher(Options{})
+	tb := &types.Toolbox{GithubAPI: srv.URL}
+	tool := &types.Tool{Name: "tool", Github: "foo/tool"}
+	if err := f.handleTool(resty.New(), &types.Lock{}, map[string]string{"tool": "v1.0.0"}, t.TempDir(), tb, tool); err != nil {
+		t.Fatalf("handleTool() error = %v", err)
+	}
+	if tool.Version != "v1.0.0" {
+		t.Errorf("Expected current version to be kept, but got: %v", tool.Version)
+	}
+	if _, ok := errors.AsType[*github.RateLimitError](f.skipped.err()); !ok {
+		t.Errorf("Expected a skipped tool with a RateLimitError, but got: %v", f.skipped.err())
+	}
+}
+
+func TestFetchRecordsProgressOnNetworkError(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, size, err := fileSha256(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	retries := 0
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Target:   target,
+		Download: &types.DownloadConfig{Retries: &retries},
+		Tools: map[string]*types.Tool{
+			"a": {Github: "example/a"},
+			"b": {Github: "example/b"},
+			"c": {Github: "example/c"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	locked := func(version, url string) *types.LockedTool {
+		return &types.LockedTool{Version: version, Platforms: map[string][]types.LockedAsset{
+			hostPlatform().String(): {{Name: binaryName("tool"), Asset: "tool", URL: url, Size: size, Sha256: sum}},
+		}}
+	}
+	if err := SaveYamlFile(lockFilePath(cfgFile), &types.Lock{Tools: map[string]*types.LockedTool{
+		"a": locked("v1.0.0", srv.URL+"/a"),
+		"b": locked("v1.0.0", unreachable.URL+"/b"),
+		"c": locked("v1.0.0", srv.URL+"/c"),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{
+		Versions: map[string]string{"b": "v0.1.0", "c": "v0.1.0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = New(Options{Locked: true}).Fetch(cfgFile)
+
+	if _, ok := errors.AsType[*pkghttp.NetworkError](err); !ok {
+		t.Fatalf("Fetch() error = %v, want *http.NetworkError", err)
+	}
+	ver, err := readVersions(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "v1.0.0", "b": "v0.1.0", "c": "v0.1.0"}
+	if diff := cmp.Diff(want, ver); diff != "" {
+		t.Errorf("versions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchLocksInstalledTools(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, size, err := fileSha256(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := hostPlatform()
+	assetName := "tool-" + pf.goos + "-" + pf.goarch
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/example/tool/releases/latest" {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(&types.GithubRelease{
+				TagName: "v1.0.0",
+				Assets:  []types.Asset{{Name: assetName, BrowserDownloadURL: srvURL + "/" + assetName}},
+			})
+			return
+		}
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Target:    target,
+		GithubAPI: srv.URL,
+		Tools:     map[string]*types.Tool{"tool": {Github: "example/tool"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the target is populated by a previous fetch without lock file
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	installed := filepath.Join(target, binaryName("tool"))
+	if err := os.WriteFile(installed, []byte("installed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{
+		Versions: map[string]string{"tool": "v1.0.0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFetcher(Options{})
+	f.checkToolboxVersion = false
+	if err := f.Fetch(cfgFile); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	lock, err := readLock(lockFilePath(cfgFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &types.Lock{Tools: map[string]*types.LockedTool{
+		"tool": {Version: "v1.0.0", Platforms: map[string][]types.LockedAsset{
+			pf.String(): {{Name: "tool", Asset: assetName, URL: srv.URL + "/" + assetName, Size: size, Sha256: sum}},
+		}},
+	}}
+	if diff := cmp.Diff(want, lock); diff != "" {
+		t.Errorf("lock mismatch (-want +got):\n%s", diff)
+	}
+	if b, err := os.ReadFile(installed); err != nil || string(b) != "installed" {
+		t.Errorf("Expected the installed tool to be kept, but got: %q, %v", b, err)
+	}
+
+	if err := New(Options{Locked: true}).Fetch(cfgFile); err != nil {
+		t.Fatalf("locked Fetch() error = %v", err)
+	}
+}
+
+func TestFetchMarksToolWithRejectedArchiveInvalid(t *testing.T) {
+	t.Setenv(cache.EnvCacheDir, t.TempDir())
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	gw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{"bad", "other"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: 4, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte("tool")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad.tar.gz" {
+			_, _ = w.Write(archive.Bytes())
+			return
+		}
+		http.ServeFile(w, r, exe)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Target:  target,
+		Extract: &types.ExtractConfig{MaxEntries: 1},
+		Tools: map[string]*types.Tool{
+			"bad":  {DownloadURL: srv.URL + "/bad.tar.gz", Version: "v1.0.0"},
+			"good": {DownloadURL: srv.URL + "/good", Version: "v1.0.0"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFetcher(Options{})
+	f.checkToolboxVersion = false
+	if err := f.Fetch(cfgFile); err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	ver, err := readVersions(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"good": "v1.0.0"}, ver); diff != "" {
+		t.Errorf("versions mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(target, binaryName("good"))); err != nil {
+		t.Errorf("Expected the valid tool to be installed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, binaryName("bad"))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the tool with the rejected archive not to be installed: %v", err)
+	}
+}
+
+func TestExtensionWeight(t *testing.T) {
+	names := []string{
+		"tool-linux-amd64",
+		"tool-linux-amd64.gz",
+		"tool-linux-amd64.tar",
+		"tool-linux-amd64.7z",
+		"tool-linux-amd64.tar.bz2",
+		"tool-linux-amd64.tar.zst",
+		"tool-linux-amd64.zip",
+		"tool-linux-amd64.tar.xz",
+		"tool-linux-amd64.tar.gz",
+	}
+	for i := 1; i < len(names); i++ {
+		if extensionWeight(names[i]) <= extensionWeight(names[i-1]) {
+			t.Errorf("Expected %s to be preferred over %s", names[i], names[i-1])
+		}
+	}
+	for _, name := range []string{"tool.TBZ2", "tool.tbz", "tool.tzst", "tool.txz", "tool.tgz"} {
+		if extensionWeight(name) == 0 {
+			t.Errorf("Expected %s to be a known archive", name)
+		}
+	}
+}
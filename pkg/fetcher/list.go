@@ -0,0 +1,97 @@
+package fetcher
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/gitlab"
+	"github.com/bakito/toolbox/pkg/google"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// List returns the status of all configured tools.
+// If withLatest is enabled, the latest available version of each tool is resolved.
+func List(client *resty.Client, cfgFile string, withLatest bool) ([]types.ToolStatus, error) {
+	tb, _, err := ReadToolbox(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	sanitizeTargetDir(tb)
+	var responses *cache.Cache
+	var ttl time.Duration
+	if withLatest {
+		t, err := transport(tb)
+		if err != nil {
+			return nil, err
+		}
+		client.SetTransport(t)
+		if responses, ttl, err = responseCache(tb); err != nil {
+			return nil, err
+		}
+	}
+
+	ver, err := readVersions(tb.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	var status []types.ToolStatus
+	for _, tool := range tb.GetTools() {
+		ts := types.ToolStatus{
+			Name:      tool.Name,
+			Source:    tool.Source(),
+			Pinned:    tool.PinnedVersion(),
+			Installed: ver[tool.Name],
+		}
+
+		ts.Exists, err = fileExists(filepath.Join(tb.Target, binaryName(tool.Name)))
+		if err != nil {
+			return nil, err
+		}
+
+		if withLatest {
+			ts.Latest, err = latestVersion(client, tb, tool, responses, ttl)
+			if err != nil {
+				log.Printf("⚠️ Could not resolve latest version of %s: %v", tool.Name, err)
+			}
+		}
+		status = append(status, ts)
+	}
+	return status, nil
+}
+
+func latestVersion(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	responses *cache.Cache,
+	ttl time.Duration,
+) (string, error) {
+	if tool.Github != "" {
+		api := withResponseCache(githubAPI(tb, tool), responses, ttl, false)
+		ghr, err := api.LatestRelease(client, tool.Github, true)
+		if err != nil {
+			return "", err
+		}
+		return ghr.TagName, nil
+	}
+	if tool.Gitlab != "" {
+		glr, err := gitlab.LatestRelease(client, tool.GitlabURL, tool.Gitlab, true)
+		if err != nil {
+			return "", err
+		}
+		return glr.TagName, nil
+	}
+	if strings.HasPrefix(tool.Version, "http") {
+		return fetchVersion(client, tool, tool.Version)
+	}
+	if tool.Google != "" {
+		return google.LatestVersion(client, tool.Google)
+	}
+	return "", nil
+}
@@ -0,0 +1,52 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile := filepath.Join(dir, toolboxConfFile)
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{
+		Target: target,
+		Tools: map[string]*types.Tool{
+			"gh":      {Github: "cli/cli"},
+			"kubectl": {DownloadURL: "https://dl.k8s.io/kubectl", Version: "https://dl.k8s.io/stable.txt"},
+			"helm":    {Github: "helm/helm", DownloadURL: "https://get.helm.sh/helm.tar.gz", Version: "v3.0.0"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{
+		Versions: map[string]string{"gh": "v2.0.0", "helm": "v3.0.0"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, binaryName("gh")), []byte("gh"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := List(nil, cfgFile, false)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	expected := []types.ToolStatus{
+		{Name: "gh", Source: types.SourceGithub, Installed: "v2.0.0", Exists: true},
+		{Name: "helm", Source: types.SourceDownloadURL, Pinned: "v3.0.0", Installed: "v3.0.0"},
+		{Name: "kubectl", Source: types.SourceDownloadURL},
+	}
+	if diff := cmp.Diff(expected, status); diff != "" {
+		t.Errorf("List() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,186 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/quietly"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const lockFileExtension = ".lock"
+
+// lockFilePath returns the path of the lock file belonging to the given config file.
+func lockFilePath(tbFile string) string {
+	return strings.TrimSuffix(tbFile, filepath.Ext(tbFile)) + lockFileExtension
+}
+
+func readLock(path string) (*types.Lock, error) {
+	lock := &types.Lock{Tools: make(map[string]*types.LockedTool)}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return lock, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, lock); err != nil {
+		return nil, err
+	}
+	if lock.Tools == nil {
+		lock.Tools = make(map[string]*types.LockedTool)
+	}
+	return lock, nil
+}
+
+// recorder collects the assets downloaded during the current run.
+type recorder struct {
+	mu    sync.Mutex
+	tools map[string]*types.LockedTool
+}
+
+// recordLock records a downloaded asset of the given tool for the current platform.
+func (f *fetcher) recordLock(tool *types.Tool, toolName, url, path string) error {
+	sum, size, err := fileSha256(path)
+	if err != nil {
+		return err
+	}
+	f.recorded.mu.Lock()
+	defer f.recorded.mu.Unlock()
+	lt, ok := f.recorded.tools[tool.Name]
+	if !ok {
+		lt = &types.LockedTool{Version: tool.Version, Platforms: make(map[string][]types.LockedAsset)}
+		f.recorded.tools[tool.Name] = lt
+	}
+	lt.Platforms[f.platform.String()] = append(lt.Platforms[f.platform.String()], types.LockedAsset{
+		Name:   toolName,
+		Asset:  filepath.Base(path),
+		URL:    url,
+		Size:   size,
+		Sha256: sum,
+	})
+	return nil
+}
+
+// mergeLock merges the assets recorded during the current run into the existing lock.
+// Locked assets of other platforms are kept as long as the version did not change.
+// Tools that are not configured anymore are removed from the lock, invalid tools keep their previous lock entry.
+func mergeLock(
+	lock *types.Lock,
+	recorded map[string]*types.LockedTool,
+	tools []*types.Tool,
+	pf string,
+) *types.Lock {
+	merged := &types.Lock{Tools: make(map[string]*types.LockedTool)}
+	for _, tool := range tools {
+		old := lock.Tools[tool.Name]
+		rec, ok := recorded[tool.Name]
+		switch {
+		case !ok || tool.Invalid || tool.CouldNotBeFound:
+			if old != nil {
+				merged.Tools[tool.Name] = old
+			}
+		case old != nil && old.Version == rec.Version:
+			if old.Platforms == nil {
+				old.Platforms = make(map[string][]types.LockedAsset)
+			}
+			old.Platforms[pf] = rec.Platforms[pf]
+			merged.Tools[tool.Name] = old
+		default:
+			merged.Tools[tool.Name] = rec
+		}
+	}
+	return merged
+}
+
+// needsLock returns true if the lock has no assets of the tool version for the platform.
+func needsLock(lock *types.Lock, tool *types.Tool, pf platform) bool {
+	lt := lock.Tools[tool.Name]
+	return lt == nil || lt.Version != tool.Version || len(lt.Platforms[pf.String()]) == 0
+}
+
+// lockInstalled downloads the assets of an already installed tool to record them in the lock, without installing them.
+// Failures are only logged, as the installed tool is up to date.
+func (f *fetcher) lockInstalled(
+	client *resty.Client,
+	tb *types.Toolbox,
+	tool *types.Tool,
+	rel *release,
+	tmp, currentVersion string,
+) {
+	f.log.Printf("🔒 Recording the installed version %s in the lock file", tool.Version)
+	lf := *f
+	lf.lockOnly = true
+	couldNotBeFound := tool.CouldNotBeFound
+	if err := lf.download(client, tb, tool, rel, tmp, currentVersion); err != nil {
+		f.log.Printf("⚠️ Could not record %s in the lock file: %v", tool.Name, err)
+	}
+	// the installed tool is kept, even if its assets could not be found
+	tool.CouldNotBeFound = couldNotBeFound
+}
+
+func (f *fetcher) handleLockedTool(
+	lock *types.Lock,
+	ver map[string]string,
+	tmp string,
+	tb *types.Toolbox,
+	tool *types.Tool,
+) error {
+	f.log.Printf("🛠  Processing %s\n", tool.Name)
+	defer fmt.Fprintln(f.out)
+
+	lt := lock.Tools[tool.Name]
+	if lt == nil || len(lt.Platforms[f.platform.String()]) == 0 {
+		return fmt.Errorf("tool %q is not locked for platform %s, run 'toolbox fetch' to update the lock file",
+			tool.Name, f.platform.String())
+	}
+
+	currentVersion := ver[tool.Name]
+	tool.Version = lt.Version
+	f.log.Printf("🔒 Locked Version: %s", tool.Version)
+	if tool.Version == currentVersion {
+		f.log.Print("✅ Skipping since already locked version\n")
+		f.planSkip(tool, currentVersion)
+		return nil
+	}
+
+	if f.opts.DryRun {
+		var assets []string
+		for _, a := range lt.Platforms[f.platform.String()] {
+			assets = append(assets, a.Asset)
+		}
+		f.planDownload(tool, currentVersion, assets...)
+		return nil
+	}
+
+	for _, a := range lt.Platforms[f.platform.String()] {
+		if err := f.fetchTool(tool, a.Name, a.URL, "sha256:"+a.Sha256, tmp, tb.Target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileSha256(path string) (sum string, size int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer quietly.Close(file)
+	h := sha256.New()
+	size, err = io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
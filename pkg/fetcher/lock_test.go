@@ -0,0 +1,106 @@
+package fetcher
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestLockFilePath(t *testing.T) {
+	tests := []struct {
+		tbFile   string
+		expected string
+	}{
+		{tbFile: ".toolbox.yaml", expected: ".toolbox.lock"},
+		{tbFile: "/home/xyz/.config/toolbox.yaml", expected: "/home/xyz/.config/toolbox.lock"},
+		{tbFile: "tools", expected: "tools.lock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tbFile, func(t *testing.T) {
+			if actual := lockFilePath(tt.tbFile); actual != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMergeLock(t *testing.T) {
+	linuxV1 := []types.LockedAsset{{Name: "tool", URL: "https://example.com/v1/tool-linux", Sha256: "linux1"}}
+	linuxV2 := []types.LockedAsset{{Name: "tool", URL: "https://example.com/v2/tool-linux", Sha256: "linux2"}}
+	darwinV1 := []types.LockedAsset{{Name: "tool", URL: "https://example.com/v1/tool-darwin", Sha256: "darwin1"}}
+
+	tests := []struct {
+		name     string
+		lock     *types.Lock
+		recorded map[string]*types.LockedTool
+		tools    []*types.Tool
+		expected map[string]*types.LockedTool
+	}{
+		{
+			name:     "should add a new tool",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{}},
+			recorded: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)},
+			tools:    []*types.Tool{{Name: "tool"}},
+			expected: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)},
+		},
+		{
+			name:     "should keep a tool that was not downloaded",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)}},
+			recorded: map[string]*types.LockedTool{},
+			tools:    []*types.Tool{{Name: "tool"}},
+			expected: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)},
+		},
+		{
+			name:     "should remove a tool that is not configured anymore",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)}},
+			recorded: map[string]*types.LockedTool{},
+			tools:    nil,
+			expected: map[string]*types.LockedTool{},
+		},
+		{
+			name:     "should keep other platforms of the same version",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{"tool": lockedTool("v1", "darwin/amd64", darwinV1)}},
+			recorded: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)},
+			tools:    []*types.Tool{{Name: "tool"}},
+			expected: map[string]*types.LockedTool{
+				"tool": {
+					Version: "v1",
+					Platforms: map[string][]types.LockedAsset{
+						"darwin/amd64": darwinV1,
+						"linux/amd64":  linuxV1,
+					},
+				},
+			},
+		},
+		{
+			name:     "should drop other platforms of a previous version",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{"tool": lockedTool("v1", "darwin/amd64", darwinV1)}},
+			recorded: map[string]*types.LockedTool{"tool": lockedTool("v2", "linux/amd64", linuxV2)},
+			tools:    []*types.Tool{{Name: "tool"}},
+			expected: map[string]*types.LockedTool{"tool": lockedTool("v2", "linux/amd64", linuxV2)},
+		},
+		{
+			name:     "should keep the previous version of an invalid tool",
+			lock:     &types.Lock{Tools: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)}},
+			recorded: map[string]*types.LockedTool{"tool": lockedTool("v2", "linux/amd64", linuxV2)},
+			tools:    []*types.Tool{{Name: "tool", Invalid: true}},
+			expected: map[string]*types.LockedTool{"tool": lockedTool("v1", "linux/amd64", linuxV1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := mergeLock(tt.lock, tt.recorded, tt.tools, "linux/amd64")
+			if diff := cmp.Diff(tt.expected, actual.Tools); diff != "" {
+				t.Errorf("mergeLock() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func lockedTool(version, pf string, assets []types.LockedAsset) *types.LockedTool {
+	return &types.LockedTool{Version: version, Platforms: map[string][]types.LockedAsset{pf: assets}}
+}
@@ -0,0 +1,35 @@
+package fetcher
+
+import (
+	http2 "net/http"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// configureNetwork applies the proxy and TLS config of the toolbox to the github API client and the downloads.
+func (f *fetcher) configureNetwork(tb *types.Toolbox, client *resty.Client) error {
+	t, err := transport(tb)
+	if err != nil {
+		return err
+	}
+	client.SetTransport(t)
+	f.grabClient.HTTPClient = &http2.Client{Transport: t}
+	return nil
+}
+
+// transport returns the transport of the proxy and TLS config of the toolbox, overridden by the TOOLBOX_* env variables.
+func transport(tb *types.Toolbox) (*http.Transport, error) {
+	cfg, err := http.Config{
+		Proxy:              tb.Proxy,
+		NoProxy:            tb.NoProxy,
+		CAFile:             tb.CAFile,
+		InsecureSkipVerify: tb.InsecureSkipVerify,
+	}.WithEnv()
+	if err != nil {
+		return nil, err
+	}
+	return http.NewTransport(cfg)
+}
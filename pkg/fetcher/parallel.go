@@ -0,0 +1,121 @@
+package fetcher
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// processTools processes the given tools with the configured number of workers.
+// When processing in parallel, the output of each tool is buffered and printed once the tool is done.
+func (f *fetcher) processTools(
+	tb *types.Toolbox,
+	tools []*types.Tool,
+	process func(tf *fetcher, tool *types.Tool) error,
+) error {
+	if f.opts.Parallel <= 1 {
+		for _, tool := range tools {
+			if err := f.processTool(tool, process); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if tb.Upx && len(tools) > 0 && tools[0].Name == "upx" {
+		// upx is processed first, before any other tool
+		if err := f.processTool(tools[0], process); err != nil {
+			return err
+		}
+		tools = tools[1:]
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	jobs := make(chan *types.Tool)
+	for range f.opts.Parallel {
+		wg.Go(func() {
+			for tool := range jobs {
+				var buf bytes.Buffer
+				err := f.withOutput(&buf).processTool(tool, process)
+
+				mu.Lock()
+				_, _ = log.Writer().Write(buf.Bytes())
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	for _, tool := range tools {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- tool
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+func (f *fetcher) processTool(tool *types.Tool, process func(tf *fetcher, tool *types.Tool) error) error {
+	if err := process(f, tool); err != nil {
+		if _, ok := errors.AsType[*validationError](err); !ok {
+			return err
+		}
+		tool.Invalid = true
+	}
+	return nil
+}
+
+// withOutput returns a copy of the fetcher writing all output of a tool into the given buffer.
+func (f *fetcher) withOutput(buf *bytes.Buffer) *fetcher {
+	tf := *f
+	tf.log = log.New(buf, f.log.Prefix(), f.log.Flags())
+	tf.out = buf
+	tf.quiet = true
+	return &tf
+}
+
+// completed collects the tools that were processed successfully.
+type completed struct {
+	mu    sync.Mutex
+	tools map[string]bool
+}
+
+func (c *completed) add(tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tools[tool] = true
+}
+
+// keyedMutex provides a mutex per key.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the mutex of the given key and returns the corresponding unlock function.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[key] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
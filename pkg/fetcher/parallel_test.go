@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestProcessTools(t *testing.T) {
+	tests := []struct {
+		name        string
+		parallel    int
+		upx         bool
+		tools       []string
+		failing     map[string]error
+		wantErr     bool
+		wantFirst   string
+		wantInvalid []string
+	}{
+		{
+			name:     "should process all tools sequentially",
+			parallel: 1,
+			tools:    []string{"a", "b", "c"},
+		},
+		{
+			name:     "should process all tools in parallel",
+			parallel: 3,
+			tools:    []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:      "should process upx first",
+			parallel:  3,
+			upx:       true,
+			tools:     []string{"upx", "a", "b", "c"},
+			wantFirst: "upx",
+		},
+		{
+			name:        "should mark invalid tools",
+			parallel:    2,
+			tools:       []string{"a", "b", "c"},
+			failing:     map[string]error{"b": ValidationError("invalid")},
+			wantInvalid: []string{"b"},
+		},
+		{
+			name:     "should return an error",
+			parallel: 2,
+			tools:    []string{"a", "b", "c"},
+			failing:  map[string]error{"b": errors.New("failed")},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fetcher{opts: Options{Parallel: tt.parallel}, log: log.Default()}
+			var tools []*types.Tool
+			for _, name := range tt.tools {
+				tools = append(tools, &types.Tool{Name: name})
+			}
+
+			var mu sync.Mutex
+			var processed []string
+			err := f.processTools(&types.Toolbox{Upx: tt.upx}, tools, func(tf *fetcher, tool *types.Tool) error {
+				tf.log.Printf("processing %s", tool.Name)
+				mu.Lock()
+				processed = append(processed, tool.Name)
+				mu.Unlock()
+				return tt.failing[tool.Name]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processTools() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if tt.wantFirst != "" && processed[0] != tt.wantFirst {
+				t.Errorf("expected %s to be processed first, got %s", tt.wantFirst, processed[0])
+			}
+			if diff := cmp.Diff(tt.tools, processed, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("processed mismatch (-want +got):\n%s", diff)
+			}
+
+			var invalid []string
+			for _, tool := range tools {
+				if tool.Invalid {
+					invalid = append(invalid, tool.Name)
+				}
+			}
+			if diff := cmp.Diff(tt.wantInvalid, invalid); diff != "" {
+				t.Errorf("invalid mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package fetcher
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+var (
+	// knownOS the operating systems supported by go ('go tool dist list').
+	knownOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js",
+		"linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+	}
+	// knownArch the architectures supported by go ('go tool dist list').
+	knownArch = []string{
+		"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le",
+		"mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm",
+	}
+)
+
+// platform the operating system and architecture tools are fetched for.
+type platform struct {
+	goos   string
+	goarch string
+}
+
+// hostPlatform returns the platform toolbox is running on.
+func hostPlatform() platform {
+	return platform{goos: runtime.GOOS, goarch: runtime.GOARCH}
+}
+
+// newPlatform returns the platform for the given os and arch. Empty values default to the host platform.
+func newPlatform(goos, goarch string) platform {
+	p := hostPlatform()
+	if goos != "" {
+		p.goos = goos
+	}
+	if goarch != "" {
+		p.goarch = goarch
+	}
+	return p
+}
+
+// validate returns an error if the os or arch of the platform is not known.
+func (p platform) validate() error {
+	if !slices.Contains(knownOS, p.goos) {
+		return fmt.Errorf("unknown os %q, expected one of %s", p.goos, strings.Join(knownOS, ", "))
+	}
+	if !slices.Contains(knownArch, p.goarch) {
+		return fmt.Errorf("unknown arch %q, expected one of %s", p.goarch, strings.Join(knownArch, ", "))
+	}
+	return nil
+}
+
+func (p platform) String() string {
+	return p.goos + "/" + p.goarch
+}
+
+// isHost returns true if binaries of the platform can be executed on the host.
+func (p platform) isHost() bool {
+	return p == hostPlatform()
+}
+
+func (p platform) binaryName(name string) string {
+	ext := p.fileExtension()
+	if strings.HasSuffix(name, ext) {
+		return name
+	}
+	return name + ext
+}
+
+func (p platform) fileExtension() string {
+	if p.goos == "windows" {
+		return ".exe"
+	}
+	return ""
+}
+
+func (p platform) archBits() string {
+	switch p.goarch {
+	case "386", "arm", "mips", "mipsle":
+		return "32"
+	default:
+		return "64"
+	}
+}
+
+// binaryName returns the name of the binary on the host platform.
+func binaryName(name string) string {
+	return hostPlatform().binaryName(name)
+}
+
+// parsePlatform parses a platform in the format '<os>/<arch>'.
+func parsePlatform(s string) (platform, error) {
+	goos, goarch, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return platform{}, fmt.Errorf("invalid platform %q, expected '<os>/<arch>'", s)
+	}
+	p := platform{goos: goos, goarch: goarch}
+	if err := p.validate(); err != nil {
+		return platform{}, fmt.Errorf("invalid platform %q: %w", s, err)
+	}
+	return p, nil
+}
+
+// dirName returns the name of the directory holding the binaries of the platform.
+func (p platform) dirName() string {
+	return p.goos + "_" + p.goarch
+}
@@ -0,0 +1,128 @@
+package fetcher
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestNewPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		goarch   string
+		expected string
+		isHost   bool
+	}{
+		{name: "Default to host", expected: runtime.GOOS + "/" + runtime.GOARCH, isHost: true},
+		{name: "Override arch", goarch: "fakearch", expected: runtime.GOOS + "/fakearch"},
+		{name: "Override os and arch", goos: "fakeos", goarch: "fakearch", expected: "fakeos/fakearch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pf := newPlatform(tt.goos, tt.goarch)
+			if pf.String() != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, pf.String())
+			}
+			if pf.isHost() != tt.isHost {
+				t.Errorf("Expected isHost: %v, but got: %v", tt.isHost, pf.isHost())
+			}
+		})
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	foreign := "plan9"
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "Host platform"},
+		{name: "Host platform with explicit os and arch", opts: Options{OS: runtime.GOOS, Arch: runtime.GOARCH}},
+		{name: "Unknown os", opts: Options{OS: "fakeos", Target: "dist"}, wantErr: true},
+		{name: "Unknown arch", opts: Options{Arch: "fakearch", Target: "dist"}, wantErr: true},
+		{name: "Foreign platform with target", opts: Options{OS: foreign, Target: "dist"}},
+		{name: "Foreign platform without target", opts: Options{OS: foreign}, wantErr: true},
+		{name: "Foreign platform dry-run", opts: Options{OS: foreign, DryRun: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := newFetcher(tt.opts).checkPlatform(); (err != nil) != tt.wantErr {
+				t.Errorf("checkPlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlatformBinaryName(t *testing.T) {
+	tests := []struct {
+		name     string
+		pf       platform
+		tool     string
+		expected string
+	}{
+		{name: "Linux", pf: platform{goos: "linux", goarch: "arm64"}, tool: "tool", expected: "tool"},
+		{name: "Windows", pf: platform{goos: "windows", goarch: "amd64"}, tool: "tool", expected: "tool.exe"},
+		{name: "Windows with extension", pf: platform{goos: "windows", goarch: "amd64"}, tool: "tool.exe", expected: "tool.exe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := tt.pf.binaryName(tt.tool); actual != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	expected := map[string]string{
+		"Version":    "v1.2.3",
+		"VersionNum": "1.2.3",
+		"OS":         "windows",
+		"Arch":       "386",
+		"ArchBIT":    "32",
+		"FileExt":    ".exe",
+	}
+	actual := templateData("v1.2.3", platform{goos: "windows", goarch: "386"})
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("templateData() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindMatchingForPlatform(t *testing.T) {
+	assets := []types.Asset{
+		{Name: "tool-linux-amd64.tar.gz"},
+		{Name: "tool-linux-arm64.tar.gz"},
+		{Name: "tool-darwin-arm64.tar.gz"},
+		{Name: "tool-windows-amd64.zip"},
+	}
+	tests := []struct {
+		name     string
+		pf       platform
+		expected string
+	}{
+		{name: "linux/amd64", pf: platform{goos: "linux", goarch: "amd64"}, expected: "tool-linux-amd64.tar.gz"},
+		{name: "linux/arm64", pf: platform{goos: "linux", goarch: "arm64"}, expected: "tool-linux-arm64.tar.gz"},
+		{name: "darwin/arm64", pf: platform{goos: "darwin", goarch: "arm64"}, expected: "tool-darwin-arm64.tar.gz"},
+		{name: "windows/amd64", pf: platform{goos: "windows", goarch: "amd64"}, expected: "tool-windows-amd64.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := findMatching(nil, tt.pf, "tool", assets)
+			if actual == nil {
+				t.Fatalf("Expected: %v, but got nil", tt.expected)
+			}
+			if actual.Name != tt.expected {
+				t.Errorf("Expected: %v, but got: %v", tt.expected, actual.Name)
+			}
+		})
+	}
+}
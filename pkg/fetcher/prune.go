@@ -0,0 +1,183 @@
+package fetcher
+
+import (
+	"errors"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const toolboxManifestFile = ".toolbox-manifest.yaml"
+
+// Prune deletes the binaries installed by toolbox, that do not belong to a configured tool anymore.
+// The orphaned files are passed to confirm before deletion, nothing is deleted if confirm returns false.
+func Prune(cfgFile string, confirm func(orphans []string) bool) error {
+	tb, _, err := ReadToolbox(cfgFile)
+	if err != nil {
+		return err
+	}
+	sanitizeTargetDir(tb)
+
+	mf, err := readManifest(tb.Target)
+	if err != nil {
+		return err
+	}
+	ver, err := readVersions(tb.Target)
+	if err != nil {
+		return err
+	}
+
+	orphans, err := findOrphans(tb, mf, manifestPlatform(mf))
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		log.Print("✅ No orphaned tools found")
+		return nil
+	}
+	if !confirm(orphans) {
+		return nil
+	}
+
+	for _, o := range orphans {
+		path := filepath.Join(tb.Target, o)
+		// orphans might be the dir of a tree
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		log.Printf("🗑️  Delete orphaned tool %s\n", path)
+		delete(ver, mf.Files[o])
+		delete(mf.Files, o)
+	}
+
+	if err := SaveYamlFile(filepath.Join(tb.Target, toolboxVersionsFile), &types.Versions{Versions: ver}); err != nil {
+		return err
+	}
+	return SaveYamlFile(filepath.Join(tb.Target, toolboxManifestFile), mf)
+}
+
+// findOrphans returns the files of the manifest existing in the target dir, that are neither a configured tool
+// nor one of its additional tools, and the trees of tools that are not configured anymore.
+func findOrphans(tb *types.Toolbox, mf *types.Manifest, pf platform) ([]string, error) {
+	expected := make(map[string]bool)
+	trees := make(map[string]bool)
+	for _, tool := range tb.GetTools() {
+		for _, file := range toolFiles(tool, pf) {
+			expected[file] = true
+		}
+		if tool.TreeInstall() {
+			trees[tool.Name] = true
+		}
+	}
+
+	var orphans []string
+	for _, file := range slices.Sorted(maps.Keys(mf.Files)) {
+		if expected[file] {
+			continue
+		}
+		ok, err := fileExists(filepath.Join(tb.Target, file))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			orphans = append(orphans, file)
+		}
+	}
+
+	dirs, err := os.ReadDir(filepath.Join(tb.Target, treeDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if d.IsDir() && !trees[d.Name()] {
+			orphans = append(orphans, filepath.Join(treeDir, d.Name()))
+		}
+	}
+	return orphans, nil
+}
+
+func readManifest(target string) (*types.Manifest, error) {
+	mf := &types.Manifest{Files: make(map[string]string)}
+	b, err := os.ReadFile(filepath.Join(target, toolboxManifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return mf, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, mf); err != nil {
+		return nil, err
+	}
+	if mf.Files == nil {
+		mf.Files = make(map[string]string)
+	}
+	return mf, nil
+}
+
+// updateManifest adds the existing binaries of all installed tools of the platform to the manifest.
+func updateManifest(tb *types.Toolbox, pf platform) error {
+	mf, err := readManifest(tb.Target)
+	if err != nil {
+		return err
+	}
+	mf.Platform = pf.String()
+	installed := tb.Versions().Versions
+	for _, tool := range tb.GetTools() {
+		if _, ok := installed[tool.Name]; !ok {
+			continue
+		}
+		for _, file := range toolFiles(tool, pf) {
+			ok, err := fileExists(filepath.Join(tb.Target, file))
+			if err != nil {
+				return err
+			}
+			if ok {
+				mf.Files[file] = tool.Name
+			}
+		}
+	}
+	return SaveYamlFile(filepath.Join(tb.Target, toolboxManifestFile), mf)
+}
+
+// manifestPlatform returns the platform the tools of the manifest were fetched for, the host platform if unknown.
+func manifestPlatform(mf *types.Manifest) platform {
+	if pf, err := parsePlatform(mf.Platform); err == nil {
+		return pf
+	}
+	return hostPlatform()
+}
+
+// removeFromManifest removes all files of the given tool from the manifest.
+func removeFromManifest(target, toolName string) error {
+	mf, err := readManifest(target)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for file, tool := range mf.Files {
+		if tool == toolName {
+			delete(mf.Files, file)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return SaveYamlFile(filepath.Join(target, toolboxManifestFile), mf)
+}
+
+func fileExists(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,187 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		confirm     bool
+		wantOrphans []string
+		wantFiles   []string
+	}{
+		{
+			name:        "should delete orphaned tools",
+			confirm:     true,
+			wantOrphans: []string{binaryName("kubectx"), binaryName("kubens")},
+			wantFiles:   []string{binaryName("gh"), binaryName("other")},
+		},
+		{
+			name:        "should not delete orphaned tools if not confirmed",
+			confirm:     false,
+			wantOrphans: []string{binaryName("kubectx"), binaryName("kubens")},
+			wantFiles:   []string{binaryName("gh"), binaryName("kubectx"), binaryName("kubens"), binaryName("other")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "bin")
+			cfgFile := filepath.Join(dir, toolboxConfFile)
+			setupTarget(t, cfgFile, target, installedTarget{
+				tools:    map[string]*types.Tool{"gh": {Github: "cli/cli"}},
+				versions: map[string]string{"gh": "v2.0.0", "kubectx": "v0.9.5"},
+				manifest: map[string]string{"gh": "gh", "kubectx": "kubectx", "kubens": "kubectx", "removed": "removed"},
+				// 'other' was not installed by toolbox and must never be deleted
+				binaries: []string{"gh", "kubectx", "kubens", "other"},
+			})
+
+			var orphans []string
+			err := Prune(cfgFile, func(o []string) bool {
+				orphans = o
+				return tt.confirm
+			})
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantOrphans, orphans); diff != "" {
+				t.Errorf("orphans mismatch (-want +got):\n%s", diff)
+			}
+
+			var files []string
+			entries, err := os.ReadDir(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != toolboxVersionsFile && e.Name() != toolboxManifestFile {
+					files = append(files, e.Name())
+				}
+			}
+			if diff := cmp.Diff(tt.wantFiles, files); diff != "" {
+				t.Errorf("files mismatch (-want +got):\n%s", diff)
+			}
+
+			if tt.confirm {
+				ver, err := readVersions(target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(map[string]string{"gh": "v2.0.0"}, ver); diff != "" {
+					t.Errorf("versions mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateManifest(t *testing.T) {
+	for _, pf := range []platform{hostPlatform(), {goos: "windows", goarch: "amd64"}} {
+		t.Run(pf.String(), func(t *testing.T) {
+			target := t.TempDir()
+			for _, name := range []string{"gh", "kubectx", "other"} {
+				if err := os.WriteFile(filepath.Join(target, pf.binaryName(name)), []byte(name), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tb := &types.Toolbox{
+				Target: target,
+				Tools: map[string]*types.Tool{
+					"gh":      {Github: "cli/cli", Version: "v2.0.0"},
+					"kubectx": {Github: "ahmetb/kubectx", Version: "v0.9.5", Additional: []string{"kubens"}},
+					"invalid": {Github: "invalid/invalid", Version: "v1.0.0", Invalid: true},
+				},
+			}
+			if err := updateManifest(tb, pf); err != nil {
+				t.Fatalf("updateManifest() error = %v", err)
+			}
+			mf, err := readManifest(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := &types.Manifest{
+				Platform: pf.String(),
+				Files:    map[string]string{pf.binaryName("gh"): "gh", pf.binaryName("kubectx"): "kubectx"},
+			}
+			if diff := cmp.Diff(expected, mf); diff != "" {
+				t.Errorf("manifest mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindOrphansOfManifestPlatform(t *testing.T) {
+	target := t.TempDir()
+	for _, name := range []string{"gh.exe", "removed.exe"} {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tb := &types.Toolbox{Target: target, Tools: map[string]*types.Tool{"gh": {Github: "cli/cli"}}}
+	mf := &types.Manifest{
+		Platform: "windows/amd64",
+		Files:    map[string]string{"gh.exe": "gh", "removed.exe": "removed"},
+	}
+	orphans, err := findOrphans(tb, mf, manifestPlatform(mf))
+	if err != nil {
+		t.Fatalf("findOrphans() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"removed.exe"}, orphans); diff != "" {
+		t.Errorf("orphans mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// installedTarget a config with a target dir populated by previous fetches.
+type installedTarget struct {
+	// tools the configured tools
+	tools map[string]*types.Tool
+	// versions the installed versions of the tools, recorded in the versions and the lock file
+	versions map[string]string
+	// manifest the files installed by toolbox, mapped to the tool they belong to
+	manifest map[string]string
+	// binaries the names of the binaries in the target dir
+	binaries []string
+}
+
+// setupTarget writes the config and the state of its populated target dir.
+func setupTarget(t *testing.T, cfgFile, target string, it installedTarget) {
+	t.Helper()
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(cfgFile, &types.Toolbox{Target: target, Tools: it.tools}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{Versions: it.versions}); err != nil {
+		t.Fatal(err)
+	}
+	lock := &types.Lock{Tools: make(map[string]*types.LockedTool)}
+	for name, version := range it.versions {
+		lock.Tools[name] = &types.LockedTool{Version: version}
+	}
+	if err := SaveYamlFile(lockFilePath(cfgFile), lock); err != nil {
+		t.Fatal(err)
+	}
+	if it.manifest != nil {
+		mf := &types.Manifest{Files: make(map[string]string)}
+		for name, tool := range it.manifest {
+			mf.Files[binaryName(name)] = tool
+		}
+		if err := SaveYamlFile(filepath.Join(target, toolboxManifestFile), mf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range it.binaries {
+		if err := os.WriteFile(filepath.Join(target, binaryName(name)), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+// Remove removes the tool from the config, the versions and the lock file.
+// Unless keepBinary is enabled, the binaries of the tool are deleted from the target dir.
+func Remove(cfgFile, toolName string, keepBinary bool) error {
+	tb, tbFile, err := ReadToolbox(cfgFile)
+	if err != nil {
+		return err
+	}
+
+	tool, ok := tb.Tools[toolName]
+	if !ok {
+		return fmt.Errorf("tool %q is not configured in %s", toolName, tbFile)
+	}
+	// versions, lock and trees are keyed by the name of the tool, that might differ from the key in the config
+	if tool.Name == "" {
+		tool.Name = toolName
+	}
+
+	log.Printf("↗️ Removing tool %s\n", toolName)
+	delete(tb.Tools, toolName)
+	log.Println("💾 Saving config")
+	if err := SaveYamlFile(tbFile, tb); err != nil {
+		return err
+	}
+
+	sanitizeTargetDir(tb)
+
+	if !keepBinary {
+		mf, err := readManifest(tb.Target)
+		if err != nil {
+			return err
+		}
+		for _, file := range toolFiles(tool, manifestPlatform(mf)) {
+			if err := deleteBinary(tb.Target, file); err != nil {
+				return err
+			}
+		}
+		if err := deleteTree(tb.Target, tool.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := removeVersion(tb.Target, tool.Name); err != nil {
+		return err
+	}
+	if err := removeFromManifest(tb.Target, tool.Name); err != nil {
+		return err
+	}
+	return removeLock(lockFilePath(tbFile), tool.Name)
+}
+
+func deleteBinary(target, file string) error {
+	path := filepath.Join(target, file)
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	log.Printf("🗑️  Delete tool %s\n", path)
+	return nil
+}
+
+// deleteTree deletes all installed versions of a tool with install mode tree.
+func deleteTree(target, toolName string) error {
+	dir := filepath.Join(target, treeDir, toolName)
+	if ok, err := fileExists(dir); !ok || err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	log.Printf("🗑️  Delete tool tree %s\n", dir)
+	return nil
+}
+
+func removeVersion(target, toolName string) error {
+	ver, err := readVersions(target)
+	if err != nil {
+		return err
+	}
+	if _, ok := ver[toolName]; !ok {
+		return nil
+	}
+	delete(ver, toolName)
+	return SaveYamlFile(filepath.Join(target, toolboxVersionsFile), &types.Versions{Versions: ver})
+}
+
+func removeLock(lockFile, toolName string) error {
+	lock, err := readLock(lockFile)
+	if err != nil {
+		return err
+	}
+	if _, ok := lock.Tools[toolName]; !ok {
+		return nil
+	}
+	delete(lock.Tools, toolName)
+	return SaveYamlFile(lockFile, lock)
+}
@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name       string
+		tool       string
+		keepBinary bool
+		wantErr    bool
+		wantTools  []string
+		wantFiles  []string
+	}{
+		{
+			name:      "should remove the tool and its binaries",
+			tool:      "kubectx",
+			wantTools: []string{"gh", "kubectl"},
+			wantFiles: []string{binaryName("gh"), binaryName("kubectl")},
+		},
+		{
+			name:       "should remove the tool and keep its binaries",
+			tool:       "kubectx",
+			keepBinary: true,
+			wantTools:  []string{"gh", "kubectl"},
+			wantFiles:  []string{binaryName("gh"), binaryName("kubectl"), binaryName("kubectx"), binaryName("kubens")},
+		},
+		{
+			name:      "should remove a tool with a name differing from its key",
+			tool:      "k",
+			wantTools: []string{"gh", "kubectx"},
+			wantFiles: []string{binaryName("gh"), binaryName("kubectx"), binaryName("kubens")},
+		},
+		{
+			name:      "should fail if the tool is not configured",
+			tool:      "unknown",
+			wantErr:   true,
+			wantTools: []string{"gh", "kubectl", "kubectx"},
+			wantFiles: []string{
+				binaryName("gh"), binaryName("kubectl"), binaryName("kubectx"), binaryName("kubens"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "bin")
+			cfgFile := filepath.Join(dir, toolboxConfFile)
+			setupTarget(t, cfgFile, target, installedTarget{
+				tools: map[string]*types.Tool{
+					"gh": {Github: "cli/cli"},
+					"k": {
+						Name:        "kubectl",
+						DownloadURL: "https://dl.k8s.io/release/{{.Version}}/bin/{{.OS}}/{{.Arch}}/kubectl",
+					},
+					"kubectx": {Github: "ahmetb/kubectx", Additional: []string{"kubens"}},
+				},
+				versions: map[string]string{"gh": "v2.0.0", "kubectl": "v1.30.0", "kubectx": "v0.9.5"},
+				binaries: []string{"gh", "kubectl", "kubectx", "kubens"},
+			})
+
+			err := Remove(cfgFile, tt.tool, tt.keepBinary)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Remove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			tb, _, err := ReadToolbox(cfgFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tools []string
+			for _, tool := range tb.GetTools() {
+				tools = append(tools, tool.Name)
+			}
+			if diff := cmp.Diff(tt.wantTools, tools); diff != "" {
+				t.Errorf("tools mismatch (-want +got):\n%s", diff)
+			}
+
+			ver, err := readVersions(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(len(tt.wantTools), len(ver)); diff != "" {
+				t.Errorf("versions mismatch (-want +got):\n%s", diff)
+			}
+
+			lock, err := readLock(lockFilePath(cfgFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(len(tt.wantTools), len(lock.Tools)); diff != "" {
+				t.Errorf("lock mismatch (-want +got):\n%s", diff)
+			}
+
+			var files []string
+			entries, err := os.ReadDir(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != toolboxVersionsFile {
+					files = append(files, e.Name())
+				}
+			}
+			if diff := cmp.Diff(tt.wantFiles, files); diff != "" {
+				t.Errorf("files mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
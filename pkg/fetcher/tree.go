@@ -0,0 +1,269 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bakito/toolbox/pkg/extract"
+	"github.com/bakito/toolbox/pkg/quietly"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const (
+	// treeDir the dir in the target dir, the trees of the tools with install mode tree are installed into.
+	treeDir = ".toolbox"
+	// shimExtension the extension of the shim scripts calling the executables of a tree on windows.
+	shimExtension = ".cmd"
+	// installPrefix the prefix of the dir a tree is extracted to, before it is moved to its version dir.
+	installPrefix = ".install-"
+)
+
+// installTree installs the whole archive into '<target>/.toolbox/<tool>/<version>/' and links the executables
+// of the tool into the target dir. Other versions of the tool are deleted.
+func (f *fetcher) installTree(tool *types.Tool, path, fileName, targetDir string) error {
+	toolDir := filepath.Join(targetDir, treeDir, tool.Name)
+	if err := os.MkdirAll(toolDir, 0o755); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp(toolDir, installPrefix)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	extracted, err := extract.File(path, staging, f.archiveExtractOptions())
+	if err != nil {
+		return f.extractionError(fileName, err)
+	}
+	// archives are extracted completely, a single binary is the only file of the tree
+	if extracted.Archive {
+		f.logExtracted(extracted)
+	} else if err := f.copyFile(path, filepath.Join(staging, f.platform.binaryName(tool.Name))); err != nil {
+		return err
+	}
+
+	// find the executables before installing the version, to not install a tree without the tool
+	executables := make(map[string]string)
+	for i, name := range append([]string{tool.Name}, tool.Additional...) {
+		exe, err := findExecutable(staging, name, f.platform)
+		if err != nil {
+			return err
+		}
+		if exe == "" {
+			if i == 0 {
+				return fmt.Errorf("could not find: %s in %s", name, fileName)
+			}
+			continue
+		}
+		if executables[name], err = filepath.Rel(staging, exe); err != nil {
+			return err
+		}
+	}
+
+	versionDir := filepath.Join(toolDir, treeVersion(tool.Version))
+	if err := os.RemoveAll(versionDir); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, versionDir); err != nil {
+		return err
+	}
+	f.log.Printf("🌳 Installed %s %s to %s", tool.Name, tool.Version, versionDir)
+
+	for _, name := range append([]string{tool.Name}, tool.Additional...) {
+		exe, ok := executables[name]
+		if !ok {
+			continue
+		}
+		exe = filepath.Join(versionDir, exe)
+		link, err := f.linkExecutable(targetDir, name, exe)
+		if err != nil {
+			return err
+		}
+		if name == tool.Name {
+			if err := f.validateTree(exe, link, tool.Check); err != nil {
+				return err
+			}
+		}
+	}
+	f.deleteOtherVersions(toolDir, versionDir)
+	return nil
+}
+
+// validateTree validates the main executable of a tree, the arch of scripts can not be checked.
+// The check is run via the link, to ensure the link works.
+func (f *fetcher) validateTree(exe, link, check string) error {
+	script, err := isScript(exe)
+	if err != nil {
+		return err
+	}
+	if script {
+		f.log.Print("📐 Skipping arch check of script")
+	} else if err := f.checkArch(exe); err != nil {
+		return err
+	}
+	return f.runCheck(link, check)
+}
+
+// linkExecutable links the executable into the target dir and returns the path of the link.
+// For windows a shim script is created, as symlinks require special privileges.
+func (f *fetcher) linkExecutable(targetDir, name, exe string) (string, error) {
+	rel, err := filepath.Rel(targetDir, exe)
+	if err != nil {
+		return "", err
+	}
+	if f.platform.goos == "windows" {
+		// a binary of the binary install mode would take precedence over the shim
+		if err := removeFile(filepath.Join(targetDir, f.platform.binaryName(name))); err != nil {
+			return "", err
+		}
+		shim := filepath.Join(targetDir, name+shimExtension)
+		content := fmt.Sprintf("@echo off\r\n\"%%~dp0%s\" %%*\r\n", strings.ReplaceAll(rel, "/", `\`))
+		if err := os.WriteFile(shim, []byte(content), 0o755); err != nil { //nolint:gosec // shim must be executable
+			return "", err
+		}
+		f.log.Printf("🔗 Created shim %s", shim)
+		return shim, nil
+	}
+
+	link := filepath.Join(targetDir, f.platform.binaryName(name))
+	if err := removeFile(link); err != nil {
+		return "", err
+	}
+	if err := os.Symlink(rel, link); err != nil {
+		return "", err
+	}
+	f.log.Printf("🔗 Linked %s to %s", link, rel)
+	return link, nil
+}
+
+// deleteOtherVersions deletes the versions of the tool except the installed version.
+// Versions that can not be deleted (e.g. an executable in use on windows) are renamed and deleted with the next fetch.
+func (f *fetcher) deleteOtherVersions(toolDir, versionDir string) {
+	entries, err := os.ReadDir(toolDir)
+	if err != nil {
+		f.log.Printf("⚠️ Could not delete old versions: %v", err)
+		return
+	}
+	for _, e := range entries {
+		dir := filepath.Join(toolDir, e.Name())
+		if dir == versionDir || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			renameTo := filepath.Join(toolDir, oldExecutablePrefix+e.Name())
+			if err := os.Rename(dir, renameTo); err != nil {
+				f.log.Printf("⚠️ Could not delete old version %s: %v", dir, err)
+				continue
+			}
+			f.log.Printf("🔀 Rename old version to %s", renameTo)
+			continue
+		}
+		f.log.Printf("🗑️  Delete old version %s", dir)
+	}
+}
+
+// deleteOldTrees deletes the leftovers of previous fetches in the tool dirs of the trees,
+// old versions that could not be deleted and interrupted installations.
+func deleteOldTrees(tb *types.Toolbox) error {
+	leftovers, err := filepath.Glob(filepath.Join(tb.Target, treeDir, "*", ".*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range leftovers {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		log.Printf("🗑️  Delete old tool version %s\n", dir)
+	}
+	return nil
+}
+
+// findExecutable returns the path of the executable in the dir, files are preferred over files in sub dirs
+// and the binary name of the platform over other matching names.
+// An empty path is returned, if the executable is not found.
+func findExecutable(dir, name string, pf platform) (string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	var match string
+	for _, file := range files {
+		p := filepath.Join(dir, file.Name())
+		if file.IsDir() {
+			dirs = append(dirs, p)
+			continue
+		}
+		if !nameMatches(file.Name(), name, pf) {
+			continue
+		}
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if file.Name() == pf.binaryName(name) {
+			return p, nil
+		}
+		if match == "" {
+			match = p
+		}
+	}
+	if match != "" {
+		return match, nil
+	}
+	for _, d := range dirs {
+		exe, err := findExecutable(d, name, pf)
+		if exe != "" || err != nil {
+			return exe, err
+		}
+	}
+	return "", nil
+}
+
+// toolFiles returns the names of the files a tool may install into the target dir for the platform,
+// the binaries of the tool and its additional binaries, and the shims of a tree.
+func toolFiles(tool *types.Tool, pf platform) []string {
+	var files []string
+	for _, name := range append([]string{tool.Name}, tool.Additional...) {
+		files = append(files, pf.binaryName(name))
+		if tool.TreeInstall() {
+			files = append(files, name+shimExtension)
+		}
+	}
+	return files
+}
+
+// treeVersion returns the name of the dir of the version.
+func treeVersion(version string) string {
+	v := strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(version)
+	if v == "" || strings.HasPrefix(v, ".") {
+		return "current" + v
+	}
+	return v
+}
+
+// isScript returns true if the file starts with a shebang.
+func isScript(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer quietly.Close(file)
+	b := make([]byte, 2)
+	n, err := io.ReadFull(file, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return string(b[:n]) == "#!", nil
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
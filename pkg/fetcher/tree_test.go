@@ -0,0 +1,200 @@
+package fetcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestInstallTree(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	archive := treeArchive(t, dir)
+	tool := &types.Tool{Name: "tool", Version: "v1.0.0", InstallMode: types.InstallModeTree, Additional: []string{"helper"}}
+
+	f := newFetcher(Options{})
+	f.platform = platform{goos: "linux", goarch: "amd64"}
+	if err := f.installTree(tool, archive, "tool.tar.gz", target); err != nil {
+		t.Fatalf("installTree() error = %v", err)
+	}
+
+	for _, name := range []string{"tool", "helper"} {
+		link, err := os.Readlink(filepath.Join(target, name))
+		if err != nil {
+			t.Fatalf("expected %s to be a symlink: %v", name, err)
+		}
+		if !strings.HasPrefix(link, filepath.Join(treeDir, "tool", "v1.0.0", "sdk", "bin")) {
+			t.Errorf("unexpected link target of %s: %s", name, link)
+		}
+		content, err := os.ReadFile(filepath.Join(target, name))
+		if err != nil {
+			t.Fatalf("could not read %s through the link: %v", name, err)
+		}
+		if !strings.HasPrefix(string(content), "#!/bin/sh") {
+			t.Errorf("unexpected content of %s: %q", name, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(target, treeDir, "tool", "v1.0.0", "sdk", "lib", "tool.jar")); err != nil {
+		t.Errorf("expected the whole tree to be installed: %v", err)
+	}
+
+	tool.Version = "v2.0.0"
+	if err := f.installTree(tool, archive, "tool.tar.gz", target); err != nil {
+		t.Fatalf("installTree() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"v2.0.0"}, dirNames(t, filepath.Join(target, treeDir, "tool"))); diff != "" {
+		t.Errorf("versions mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.ReadFile(filepath.Join(target, "tool")); err != nil {
+		t.Errorf("expected the link to point to the new version: %v", err)
+	}
+}
+
+func TestInstallTreeWindowsShims(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// a binary installed with the binary install mode is replaced by the shim
+	if err := os.WriteFile(filepath.Join(target, "tool.exe"), []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	archive := treeArchive(t, dir)
+	tool := &types.Tool{Name: "tool", Version: "v1.0.0", InstallMode: types.InstallModeTree}
+
+	f := newFetcher(Options{})
+	f.platform = platform{goos: "windows", goarch: "amd64"}
+	if err := f.installTree(tool, archive, "tool.tar.gz", target); err != nil {
+		t.Fatalf("installTree() error = %v", err)
+	}
+
+	shim, err := os.ReadFile(filepath.Join(target, "tool.cmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@echo off\r\n\"%~dp0.toolbox\\tool\\v1.0.0\\sdk\\bin\\tool.exe\" %*\r\n"
+	if diff := cmp.Diff(want, string(shim)); diff != "" {
+		t.Errorf("shim mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(target, "tool.exe")); !os.IsNotExist(err) {
+		t.Errorf("expected tool.exe to be deleted, got: %v", err)
+	}
+}
+
+func TestInstallTreeMissingExecutable(t *testing.T) {
+	dir := t.TempDir()
+	archive := treeArchive(t, dir)
+	tool := &types.Tool{Name: "unknown", Version: "v1.0.0", InstallMode: types.InstallModeTree}
+
+	f := newFetcher(Options{})
+	f.platform = platform{goos: "linux", goarch: "amd64"}
+	if err := f.installTree(tool, archive, "tool.tar.gz", dir); err == nil {
+		t.Error("installTree() expected an error")
+	}
+	if names := dirNames(t, filepath.Join(dir, treeDir, "unknown")); len(names) != 0 {
+		t.Errorf("expected no version to be installed, got %v", names)
+	}
+}
+
+func TestDeleteOldTrees(t *testing.T) {
+	target := t.TempDir()
+	toolDir := filepath.Join(target, treeDir, "tool")
+	for _, d := range []string{"v1.0.0", oldExecutablePrefix + "v0.9.0", installPrefix + "123"} {
+		if err := os.MkdirAll(filepath.Join(toolDir, d, "bin"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := deleteOldTrees(&types.Toolbox{Target: target}); err != nil {
+		t.Fatalf("deleteOldTrees() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0"}, dirNames(t, toolDir)); diff != "" {
+		t.Errorf("versions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTreeVersion(t *testing.T) {
+	tests := map[string]string{
+		"v1.0.0":     "v1.0.0",
+		"2024/01/01": "2024_01_01",
+		"../../etc":  "____etc",
+		"":           "current",
+		".hidden":    "current.hidden",
+	}
+	for version, want := range tests {
+		if got := treeVersion(version); got != want {
+			t.Errorf("treeVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+// treeArchive writes a tool archive with a tree containing scripts, a symlink and a library.
+func treeArchive(t *testing.T, dir string) string {
+	t.Helper()
+	src := filepath.Join(dir, "src")
+	files := map[string]string{
+		"sdk/bin/tool":         "#!/bin/sh\necho tool\n",
+		"sdk/bin/tool.exe":     "#!/bin/sh\necho tool\n",
+		"sdk/lib/helper.sh":    "#!/bin/sh\necho helper\n",
+		"sdk/lib/tool.jar":     "jar",
+		"sdk/docs/README.md":   "readme",
+		"sdk/bin/.placeholder": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil { //nolint:gosec // executable
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../lib/helper.sh", filepath.Join(src, "sdk", "bin", "helper")); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(dir, "tool.tar.gz")
+	if err := writeTarGz(src, archive); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestFindOrphanedTrees(t *testing.T) {
+	target := t.TempDir()
+	for _, tool := range []string{"configured", "removed"} {
+		if err := os.MkdirAll(filepath.Join(target, treeDir, tool, "v1.0.0"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tb := &types.Toolbox{Target: target, Tools: map[string]*types.Tool{
+		"configured": {Github: "foo/configured", InstallMode: types.InstallModeTree},
+	}}
+	orphans, err := findOrphans(tb, &types.Manifest{Files: map[string]string{}}, hostPlatform())
+	if err != nil {
+		t.Fatalf("findOrphans() error = %v", err)
+	}
+	if diff := cmp.Diff([]string{filepath.Join(treeDir, "removed")}, orphans); diff != "" {
+		t.Errorf("orphans mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -2,57 +2,116 @@
 package github
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	http2 "net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 
-	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/cache"
 	"github.com/bakito/toolbox/pkg/types"
 )
 
-const EnvGithubToken = "GITHUB_TOKEN" // #nosec G101: variable name for token
+const (
+	EnvGithubToken           = "GITHUB_TOKEN"        // #nosec G101: variable name for token
+	EnvGithubEnterpriseToken = "GH_ENTERPRISE_TOKEN" // #nosec G101: variable name for token
+	EnvGithubAPI             = "TOOLBOX_GITHUB_API"
+	DefaultAPIURL            = "https://api.github.com"
+
+	defaultAPIHost = "api.github.com"
+)
 
 var (
-	releaseURLPattern       = "https://api.github.com/repos/%s/releases/tags/%s"
-	latestReleaseURLPattern = "https://api.github.com/repos/%s/releases/latest"
-	latestTagURLPattern     = "https://api.github.com/repos/%s/tags"
+	releaseURLPattern       = "%s/repos/%s/releases/tags/%s"
+	latestReleaseURLPattern = "%s/repos/%s/releases/latest"
+	latestTagURLPattern     = "%s/repos/%s/tags"
 )
 
+// API a github API endpoint, e.g. 'https://ghe.example.com/api/v3' for a GitHub Enterprise Server.
+type API struct {
+	url       string
+	cache     *cache.Cache
+	ttl       time.Duration
+	refresh   bool
+	rateLimit *RateLimit
+	log       *log.Logger
+}
+
+// NewAPI returns the github API for the given URL, if the URL is empty, api.github.com is used.
+func NewAPI(apiURL string) *API {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &API{url: strings.TrimSuffix(apiURL, "/"), log: log.Default()}
+}
+
+// WithLogger logs the messages of the API, like retries of failed requests, to the given logger
+// instead of the standard logger.
+func (a *API) WithLogger(l *log.Logger) *API {
+	a.log = l
+	return a
+}
+
+// WithCache enables caching of the API responses. Responses younger than ttl are served from the cache,
+// older ones are revalidated with a conditional request. If refresh is enabled, cached responses are not used.
+func (a *API) WithCache(c *cache.Cache, ttl time.Duration, refresh bool) *API {
+	a.cache = c
+	a.ttl = ttl
+	a.refresh = refresh
+	return a
+}
+
+// LatestRelease returns the latest release of the repo from api.github.com.
 func LatestRelease(client *resty.Client, repo string, quiet bool) (*types.GithubRelease, error) {
+	return defaultAPI().LatestRelease(client, repo, quiet)
+}
+
+// Release returns the release of the repo with the given version from api.github.com.
+func Release(client *resty.Client, repo, version string, quiet bool) (*types.GithubRelease, error) {
+	return defaultAPI().Release(client, repo, version, quiet)
+}
+
+// defaultAPI returns the API of api.github.com, caching the responses in the default cache if it is available.
+func defaultAPI() *API {
+	api := NewAPI("")
+	if c, err := cache.Default(); err == nil {
+		api.WithCache(c, cache.DefaultResponseTTL, false)
+	}
+	return api
+}
+
+func (a *API) LatestRelease(client *resty.Client, repo string, quiet bool) (*types.GithubRelease, error) {
 	ghr := &types.GithubRelease{}
 	ghErr := &types.GithubError{}
 	ghc := client.R().
-		SetResult(ghr).
 		SetError(ghErr).
 		SetHeader("Accept", "application/json")
-	handleGithubToken(ghc, quiet)
-	url := latestReleaseURL(repo)
-	resp, err := ghc.Get(url)
+	a.handleGithubToken(ghc, quiet)
+	url := a.latestReleaseURL(repo)
+	status, err := a.get(ghc, url, ghr)
 	if err != nil {
-		return nil, http.CheckError(err)
+		return nil, err
 	}
-	if resp.IsError() && resp.StatusCode() != http2.StatusNotFound {
-		return nil, fmt.Errorf("github request was not successful: %s (%d) %s", url, resp.StatusCode(), ghErr.Message)
+	if isError(status) && status != http2.StatusNotFound {
+		return nil, fmt.Errorf("github request was not successful: %s (%d) %s", url, status, ghErr.Message)
 	}
 
 	if ghr.TagName == "" {
 		ght := &types.GithubTags{}
-		ghc.SetResult(ght).
-			SetError(ghErr).
-			SetHeader("Accept", "application/json")
-		resp, err := ghc.Get(latestTagURL(repo))
+		status, err := a.get(ghc, a.latestTagURL(repo), ght)
 		if err != nil {
-			return nil, http.CheckError(err)
+			return nil, err
 		}
-		if resp.IsError() {
+		if isError(status) {
 			return nil, fmt.Errorf(
 				"github request was not successful: %s (%d) %s",
 				url,
-				resp.StatusCode(),
+				status,
 				ghErr.Message,
 			)
 		}
@@ -70,41 +129,52 @@ func TokenSet() bool {
 	return ok && strings.TrimSpace(t) != ""
 }
 
-func handleGithubToken(ghc *resty.Request, quiet bool) {
-	if t, ok := os.LookupEnv(EnvGithubToken); ok && strings.TrimSpace(t) != "" {
+// Token returns the token for the host of the API.
+// api.github.com uses 'GITHUB_TOKEN', other hosts use 'GITHUB_TOKEN_<HOST>' (e.g. GITHUB_TOKEN_GHE_EXAMPLE_COM)
+// or 'GH_ENTERPRISE_TOKEN'.
+func (a *API) Token() string {
+	host := a.host()
+	if host == defaultAPIHost {
+		return lookupToken(EnvGithubToken)
+	}
+	if t := lookupToken(hostTokenEnv(host)); t != "" {
+		return t
+	}
+	return lookupToken(EnvGithubEnterpriseToken)
+}
+
+func (a *API) handleGithubToken(ghc *resty.Request, quiet bool) {
+	if t := a.Token(); t != "" {
 		if !quiet {
-			log.Print("🔑 Using github token\n")
+			a.log.Print("🔑 Using github token\n")
 		}
 		ghc.SetAuthToken(t)
 	}
 }
 
-func Release(client *resty.Client, repo, version string, quiet bool) (*types.GithubRelease, error) {
+func (a *API) Release(client *resty.Client, repo, version string, quiet bool) (*types.GithubRelease, error) {
 	ghr := &types.GithubRelease{}
 	ghErr := &types.GithubError{}
 
 	ghc := client.R().
-		SetResult(ghr).
 		SetError(ghErr).
 		SetHeader("Accept", "application/json")
 
-	handleGithubToken(ghc, quiet)
+	a.handleGithubToken(ghc, quiet)
 
-	url := releaseURL(repo, version)
-	resp, err := ghc.Get(releaseURL(repo, version))
+	url := a.releaseURL(repo, version)
+	status, err := a.get(ghc, url, ghr)
 	if err != nil {
-		return nil, http.CheckError(err)
+		return nil, err
 	}
-	if resp.IsError() {
-		return nil, fmt.Errorf("github request was not successful: %s (%d) %s", url, resp.StatusCode(), ghErr.Message)
+	if isError(status) {
+		return nil, fmt.Errorf("github request was not successful: %s (%d) %s", url, status, ghErr.Message)
 	}
 
 	if ghr.TagName == "" {
 		ght := &types.GithubTags{}
-		ghc.SetResult(ght)
-		_, err := ghc.Get(latestTagURL(repo))
-		if err != nil {
-			return nil, http.CheckError(err)
+		if _, err := a.get(ghc, a.latestTagURL(repo), ght); err != nil {
+			return nil, err
 		}
 
 		if latest := ght.GetLatest(); latest != nil {
@@ -115,23 +185,100 @@ func Release(client *resty.Client, repo, version string, quiet bool) (*types.Git
 	return ghr, nil
 }
 
-func latestReleaseURL(repo string) string {
+// get executes the request and parses the body of a successful response into result.
+// With a cache, fresh responses are served from the cache and stale responses are revalidated with a
+// conditional request ('If-None-Match' / 'If-Modified-Since'). A '304 Not Modified' is served from the cache.
+func (a *API) get(req *resty.Request, url string, result any) (int, error) {
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	if a.cache == nil {
+		resp, err := a.execute(req.SetResult(result), url)
+		if err != nil {
+			return 0, err
+		}
+		return resp.StatusCode(), nil
+	}
+
+	key := cache.ResponseKey(url, a.Token())
+	cached, ok := a.cache.GetResponse(key)
+	if ok && !a.refresh {
+		if cached.Fresh(a.ttl, a.cache.Now()) {
+			return http2.StatusOK, json.Unmarshal(cached.Body, result)
+		}
+		if cached.ETag != "" {
+			req.SetHeader("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.SetHeader("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := a.execute(req.SetResult(result), url)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case resp.StatusCode() == http2.StatusNotModified && ok:
+		cached.Stored = a.cache.Now()
+		_ = a.cache.PutResponse(key, cached)
+		return http2.StatusOK, json.Unmarshal(cached.Body, result)
+	case resp.IsSuccess():
+		_ = a.cache.PutResponse(key, &cache.Response{
+			URL:          url,
+			ETag:         resp.Header().Get("ETag"),
+			LastModified: resp.Header().Get("Last-Modified"),
+			Stored:       a.cache.Now(),
+			Body:         resp.Body(),
+		})
+	}
+	return resp.StatusCode(), nil
+}
+
+func isError(status int) bool {
+	return status > 399
+}
+
+func (a *API) host() string {
+	u, err := url.Parse(a.url)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func (a *API) latestReleaseURL(repo string) string {
 	if repo != "" {
-		return fmt.Sprintf(latestReleaseURLPattern, repo)
+		return fmt.Sprintf(latestReleaseURLPattern, a.url, repo)
 	}
 	return ""
 }
 
-func releaseURL(repo, version string) string {
+func (a *API) releaseURL(repo, version string) string {
 	if repo != "" {
-		return fmt.Sprintf(releaseURLPattern, repo, version)
+		return fmt.Sprintf(releaseURLPattern, a.url, repo, version)
 	}
 	return ""
 }
 
-func latestTagURL(repo string) string {
+func (a *API) latestTagURL(repo string) string {
 	if repo != "" {
-		return fmt.Sprintf(latestTagURLPattern, repo)
+		return fmt.Sprintf(latestTagURLPattern, a.url, repo)
+	}
+	return ""
+}
+
+func hostTokenEnv(host string) string {
+	return EnvGithubToken + "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(host))
+}
+
+func lookupToken(env string) string {
+	if t, ok := os.LookupEnv(env); ok {
+		return strings.TrimSpace(t)
 	}
 	return ""
 }
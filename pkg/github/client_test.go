@@ -0,0 +1,183 @@
+package github
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/cache"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestAPI_LatestRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		repo string
+		want string
+	}{
+		{name: "should return the latest release", repo: "foo/with-release", want: "v1.2.0"},
+		{name: "should return the latest tag if no release exists", repo: "foo/without-release", want: "v1.1.0"},
+	}
+
+	api := NewAPI(newServer(t).URL + "/api/v3/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := api.LatestRelease(resty.New(), tt.repo, true)
+			if err != nil {
+				t.Fatalf("LatestRelease() error = %v", err)
+			}
+			if got.TagName != tt.want {
+				t.Errorf("LatestRelease() = %v, want %v", got.TagName, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPI_Release(t *testing.T) {
+	api := NewAPI(newServer(t).URL + "/api/v3")
+
+	got, err := api.Release(resty.New(), "foo/with-release", "v1.0.0", true)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if got.TagName != "v1.0.0" {
+		t.Errorf("Release() = %v, want %v", got.TagName, "v1.0.0")
+	}
+
+	if _, err := api.Release(resty.New(), "foo/with-release", "v9.9.9", true); err == nil {
+		t.Error("Release() expected an error for an unknown release")
+	}
+}
+
+func TestAPI_Token(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "should use GITHUB_TOKEN for api.github.com",
+			url:  "",
+			env:  map[string]string{EnvGithubToken: "github", EnvGithubEnterpriseToken: "enterprise"},
+			want: "github",
+		},
+		{
+			name: "should use the host specific token",
+			url:  "https://ghe.example.com/api/v3",
+			env: map[string]string{
+				EnvGithubToken:                 "github",
+				"GITHUB_TOKEN_GHE_EXAMPLE_COM": "host",
+				EnvGithubEnterpriseToken:       "enterprise",
+			},
+			want: "host",
+		},
+		{
+			name: "should use the enterprise token",
+			url:  "https://ghe.example.com/api/v3",
+			env:  map[string]string{EnvGithubToken: "github", EnvGithubEnterpriseToken: "enterprise"},
+			want: "enterprise",
+		},
+		{
+			name: "should not use GITHUB_TOKEN for other hosts",
+			url:  "https://ghe.example.com/api/v3",
+			env:  map[string]string{EnvGithubToken: "github"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvGithubToken, "")
+			t.Setenv(EnvGithubEnterpriseToken, "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if got := NewAPI(tt.url).Token(); got != tt.want {
+				t.Errorf("Token() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPI_Cache(t *testing.T) {
+	tests := []struct {
+		name            string
+		ttl             time.Duration
+		refresh         bool
+		wantRequests    int
+		wantNotModified int
+	}{
+		{name: "should revalidate stale responses", ttl: 0, wantRequests: 2, wantNotModified: 1},
+		{name: "should serve fresh responses from the cache", ttl: time.Hour, wantRequests: 1},
+		{name: "should bypass the cache on refresh", ttl: time.Hour, refresh: true, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests, notModified int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				writeJSON(w, http.StatusOK, types.GithubRelease{TagName: "v1.2.0"})
+			}))
+			defer server.Close()
+
+			api := NewAPI(server.URL).WithCache(cache.New(t.TempDir()), tt.ttl, tt.refresh)
+			for range 2 {
+				got, err := api.LatestRelease(resty.New(), "foo/bar", true)
+				if err != nil {
+					t.Fatalf("LatestRelease() error = %v", err)
+				}
+				if got.TagName != "v1.2.0" {
+					t.Errorf("LatestRelease() = %v, want %v", got.TagName, "v1.2.0")
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+			}
+			if notModified != tt.wantNotModified {
+				t.Errorf("not modified responses = %v, want %v", notModified, tt.wantNotModified)
+			}
+		})
+	}
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/foo/with-release/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, types.GithubRelease{TagName: "v1.2.0"})
+	})
+	mux.HandleFunc("/api/v3/repos/foo/with-release/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("tag") != "v1.0.0" {
+			writeJSON(w, http.StatusNotFound, types.GithubError{Message: "Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, types.GithubRelease{TagName: "v1.0.0"})
+	})
+	mux.HandleFunc("/api/v3/repos/foo/without-release/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusNotFound, types.GithubError{Message: "Not Found"})
+	})
+	mux.HandleFunc("/api/v3/repos/foo/without-release/tags", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, types.GithubTags{{Name: "v1.0.0"}, {Name: "v1.1.0"}, {Name: "v1.2.0-rc.1"}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
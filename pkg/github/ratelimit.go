@@ -0,0 +1,137 @@
+package github
+
+import (
+	"fmt"
+	http2 "net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/http"
+)
+
+var (
+	// maxRetries the number of retries of transient errors and secondary rate limits.
+	maxRetries = 3
+	// retryBackoff the initial wait duration between retries, doubled with each retry.
+	retryBackoff = time.Second
+	// maxRetryWait the maximum duration to wait before a retry.
+	maxRetryWait = time.Minute
+
+	sleep = time.Sleep
+)
+
+// RateLimit the rate limit of the github API as reported in the response headers.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+func (r *RateLimit) String() string {
+	return fmt.Sprintf("%d/%d requests remaining, resets at %s", r.Remaining, r.Limit, r.Reset.Format(time.TimeOnly))
+}
+
+// RateLimitError the rate limit of the github API is exceeded.
+type RateLimitError struct {
+	URL       string
+	RateLimit *RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RateLimit != nil && e.RateLimit.Remaining == 0 {
+		return fmt.Sprintf("github rate limit exceeded for %s (%s)", e.URL, e.RateLimit)
+	}
+	return "github secondary rate limit exceeded for " + e.URL
+}
+
+// RateLimit returns the rate limit reported by the last response of the API.
+func (a *API) RateLimit() *RateLimit {
+	return a.rateLimit
+}
+
+// execute executes the GET request. Transient server errors and secondary rate limits are retried
+// with an exponential backoff, honoring the 'Retry-After' header.
+// A *RateLimitError is returned if the rate limit is exceeded.
+func (a *API) execute(req *resty.Request, url string) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := req.Get(url)
+		if err != nil {
+			return nil, http.CheckError(err)
+		}
+		if rl := parseRateLimit(resp.Header()); rl != nil {
+			a.rateLimit = rl
+		}
+
+		rateLimited := isRateLimited(resp)
+		if rateLimited && a.rateLimit != nil && a.rateLimit.Remaining == 0 {
+			// the primary rate limit is only reset after up to an hour, retrying makes no sense
+			return nil, &RateLimitError{URL: url, RateLimit: a.rateLimit}
+		}
+		if !rateLimited && !isTransient(resp.StatusCode()) {
+			return resp, nil
+		}
+		if attempt >= maxRetries {
+			if rateLimited {
+				return nil, &RateLimitError{URL: url, RateLimit: a.rateLimit}
+			}
+			return resp, nil
+		}
+
+		wait := retryWait(resp, attempt)
+		a.log.Printf("🔁 github request was not successful: %s (%d), retrying in %s", url, resp.StatusCode(), wait)
+		sleep(wait)
+	}
+}
+
+func parseRateLimit(h http2.Header) *RateLimit {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return nil
+	}
+	rl := &RateLimit{Remaining: remaining}
+	rl.Limit, _ = strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+	return rl
+}
+
+func isRateLimited(resp *resty.Response) bool {
+	switch resp.StatusCode() {
+	case http2.StatusTooManyRequests:
+		return true
+	case http2.StatusForbidden:
+		return resp.Header().Get("Retry-After") != "" ||
+			resp.Header().Get("X-RateLimit-Remaining") == "0" ||
+			strings.Contains(strings.ToLower(string(resp.Body())), "rate limit")
+	default:
+		return false
+	}
+}
+
+func isTransient(status int) bool {
+	switch status {
+	case http2.StatusInternalServerError, http2.StatusBadGateway,
+		http2.StatusServiceUnavailable, http2.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryWait returns the duration to wait before the next attempt.
+// The 'Retry-After' header is honored, otherwise the backoff is doubled with each attempt.
+func retryWait(resp *resty.Response, attempt int) time.Duration {
+	wait := retryBackoff << attempt
+	if ra := resp.Header().Get("Retry-After"); ra != "" {
+		if seconds, err := strconv.Atoi(ra); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if t, err := http2.ParseTime(ra); err == nil {
+			wait = time.Until(t)
+		}
+	}
+	return max(0, min(wait, maxRetryWait))
+}
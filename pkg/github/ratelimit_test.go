@@ -0,0 +1,151 @@
+package github
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+type response struct {
+	status  int
+	headers map[string]string
+}
+
+func TestAPI_Retry(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	exhausted := map[string]string{
+		"X-RateLimit-Limit":     "60",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+	}
+	tests := []struct {
+		name          string
+		responses     []response
+		wantRequests  int
+		wantWaits     []time.Duration
+		wantRateLimit bool
+		wantErr       bool
+	}{
+		{
+			name:         "should retry transient errors with backoff",
+			responses:    []response{{status: http.StatusBadGateway}, {status: http.StatusServiceUnavailable}},
+			wantRequests: 3,
+			wantWaits:    []time.Duration{time.Millisecond, 2 * time.Millisecond},
+		},
+		{
+			name:         "should honor retry after of secondary rate limits",
+			responses:    []response{{status: http.StatusForbidden, headers: map[string]string{"Retry-After": "2"}}},
+			wantRequests: 2,
+			wantWaits:    []time.Duration{2 * time.Second},
+		},
+		{
+			name:          "should not retry an exceeded rate limit",
+			responses:     []response{{status: http.StatusForbidden, headers: exhausted}},
+			wantRequests:  1,
+			wantRateLimit: true,
+			wantErr:       true,
+		},
+		{
+			name: "should fail after max retries",
+			responses: []response{
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+			},
+			wantRequests:  4,
+			wantWaits:     []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond},
+			wantRateLimit: true,
+			wantErr:       true,
+		},
+		{
+			name:         "should not retry client errors",
+			responses:    []response{{status: http.StatusUnauthorized}},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			mockRetry(t, func(d time.Duration) { waits = append(waits, d) })
+
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				requests++
+				if requests <= len(tt.responses) {
+					r := tt.responses[requests-1]
+					for k, v := range r.headers {
+						w.Header().Set(k, v)
+					}
+					writeJSON(w, r.status, types.GithubError{Message: http.StatusText(r.status)})
+					return
+				}
+				writeJSON(w, http.StatusOK, types.GithubRelease{TagName: "v1.0.0"})
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			got, err := NewAPI(server.URL).WithLogger(log.New(&logs, "", 0)).Release(resty.New(), "foo/bar", "v1.0.0", true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Release() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := errors.AsType[*RateLimitError](err); ok != tt.wantRateLimit {
+				t.Errorf("Release() RateLimitError = %v, want %v", ok, tt.wantRateLimit)
+			}
+			if !tt.wantErr && got.TagName != "v1.0.0" {
+				t.Errorf("Release() = %v, want %v", got.TagName, "v1.0.0")
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %v, want %v", requests, tt.wantRequests)
+			}
+			if diff := cmp.Diff(tt.wantWaits, waits); diff != "" {
+				t.Errorf("waits mismatch (-want +got):\n%s", diff)
+			}
+			if retries := strings.Count(logs.String(), "🔁"); retries != len(tt.wantWaits) {
+				t.Errorf("Expected %d retries logged to the API logger, but got: %q", len(tt.wantWaits), logs.String())
+			}
+		})
+	}
+}
+
+func TestAPI_RateLimit(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		writeJSON(w, http.StatusOK, types.GithubRelease{TagName: "v1.0.0"})
+	}))
+	defer server.Close()
+
+	api := NewAPI(server.URL)
+	if _, err := api.LatestRelease(resty.New(), "foo/bar", true); err != nil {
+		t.Fatalf("LatestRelease() error = %v", err)
+	}
+	want := &RateLimit{Limit: 60, Remaining: 42, Reset: reset}
+	if diff := cmp.Diff(want, api.RateLimit()); diff != "" {
+		t.Errorf("RateLimit() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func mockRetry(t *testing.T, s func(time.Duration)) {
+	t.Helper()
+	origSleep, origBackoff := sleep, retryBackoff
+	sleep, retryBackoff = s, time.Millisecond
+	t.Cleanup(func() {
+		sleep, retryBackoff = origSleep, origBackoff
+	})
+}
@@ -0,0 +1,170 @@
+// Package gitlab API functions
+package gitlab
+
+import (
+	"fmt"
+	"log"
+	http2 "net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const (
+	EnvGitlabToken = "GITLAB_TOKEN" // #nosec G101: variable name for token
+	DefaultURL     = "https://gitlab.com"
+
+	tokenHeader = "PRIVATE-TOKEN" // #nosec G101: header name for token
+)
+
+var (
+	releaseURLPattern       = "%s/api/v4/projects/%s/releases/%s"
+	latestReleaseURLPattern = "%s/api/v4/projects/%s/releases/permalink/latest"
+	releasesURLPattern      = "%s/api/v4/projects/%s/releases"
+)
+
+// API a gitlab instance, e.g. 'https://gitlab.example.com' for a self-hosted instance.
+type API struct {
+	url string
+	log *log.Logger
+}
+
+// NewAPI returns the API of the gitlab instance with the given base URL, if the URL is empty, gitlab.com is used.
+func NewAPI(baseURL string) *API {
+	return &API{url: apiBase(baseURL), log: log.Default()}
+}
+
+// WithLogger logs the messages of the API to the given logger instead of the standard logger.
+func (a *API) WithLogger(l *log.Logger) *API {
+	a.log = l
+	return a
+}
+
+// LatestRelease returns the latest release of the given project.
+// If baseURL is empty, gitlab.com is used.
+func LatestRelease(client *resty.Client, baseURL, project string, quiet bool) (*types.GitlabRelease, error) {
+	return NewAPI(baseURL).LatestRelease(client, project, quiet)
+}
+
+// Release returns the release of the given project with the given tag.
+// If baseURL is empty, gitlab.com is used.
+func Release(client *resty.Client, baseURL, project, version string, quiet bool) (*types.GitlabRelease, error) {
+	return NewAPI(baseURL).Release(client, project, version, quiet)
+}
+
+// LatestRelease returns the latest release of the given project.
+func (a *API) LatestRelease(client *resty.Client, project string, quiet bool) (*types.GitlabRelease, error) {
+	glr := &types.GitlabRelease{}
+	glErr := &types.GitlabError{}
+	glc := client.R().
+		SetResult(glr).
+		SetError(glErr).
+		SetHeader("Accept", "application/json")
+	a.handleGitlabToken(glc, quiet)
+
+	u := latestReleaseURL(a.url, project)
+	resp, err := glc.Get(u)
+	if err != nil {
+		return nil, http.CheckError(err)
+	}
+	if resp.StatusCode() == http2.StatusNotFound {
+		// instances without permalink support
+		glrs := &[]types.GitlabRelease{}
+		u = releasesURL(a.url, project)
+		resp, err = glc.SetResult(glrs).
+			SetQueryParam("per_page", "1").
+			SetQueryParam("order_by", "released_at").
+			Get(u)
+		if err != nil {
+			return nil, http.CheckError(err)
+		}
+		if resp.IsSuccess() {
+			if len(*glrs) == 0 {
+				return nil, fmt.Errorf("gitlab project %s has no releases", project)
+			}
+			return &(*glrs)[0], nil
+		}
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("gitlab request was not successful: %s (%d) %s", u, resp.StatusCode(), glErr.GetMessage())
+	}
+	return glr, nil
+}
+
+// Release returns the release of the given project with the given tag.
+func (a *API) Release(client *resty.Client, project, version string, quiet bool) (*types.GitlabRelease, error) {
+	glr := &types.GitlabRelease{}
+	glErr := &types.GitlabError{}
+	glc := client.R().
+		SetResult(glr).
+		SetError(glErr).
+		SetHeader("Accept", "application/json")
+	a.handleGitlabToken(glc, quiet)
+
+	u := releaseURL(a.url, project, version)
+	resp, err := glc.Get(u)
+	if err != nil {
+		return nil, http.CheckError(err)
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("gitlab request was not successful: %s (%d) %s", u, resp.StatusCode(), glErr.GetMessage())
+	}
+	return glr, nil
+}
+
+func TokenSet() bool {
+	t, ok := os.LookupEnv(EnvGitlabToken)
+	return ok && strings.TrimSpace(t) != ""
+}
+
+func (a *API) handleGitlabToken(glc *resty.Request, quiet bool) {
+	if t, ok := os.LookupEnv(EnvGitlabToken); ok && strings.TrimSpace(t) != "" {
+		if !quiet {
+			a.log.Print("🔑 Using gitlab token\n")
+		}
+		glc.SetHeader(tokenHeader, t)
+	}
+}
+
+// SetAssetToken sets the token header on the download of an asset, if the token is set and the asset is hosted
+// on the gitlab instance of baseURL. The token is never sent to other hosts, like external asset links.
+// If baseURL is empty, gitlab.com is used.
+func SetAssetToken(header http2.Header, baseURL, assetURL string) {
+	t, ok := os.LookupEnv(EnvGitlabToken)
+	if !ok || strings.TrimSpace(t) == "" {
+		return
+	}
+	base, err := url.Parse(apiBase(baseURL))
+	if err != nil {
+		return
+	}
+	asset, err := url.Parse(assetURL)
+	if err != nil || asset.Scheme != base.Scheme || !strings.EqualFold(asset.Host, base.Host) {
+		return
+	}
+	header.Set(tokenHeader, t)
+}
+
+func latestReleaseURL(baseURL, project string) string {
+	return fmt.Sprintf(latestReleaseURLPattern, apiBase(baseURL), url.PathEscape(project))
+}
+
+func releaseURL(baseURL, project, version string) string {
+	return fmt.Sprintf(releaseURLPattern, apiBase(baseURL), url.PathEscape(project), url.PathEscape(version))
+}
+
+func releasesURL(baseURL, project string) string {
+	return fmt.Sprintf(releasesURLPattern, apiBase(baseURL), url.PathEscape(project))
+}
+
+func apiBase(baseURL string) string {
+	if baseURL == "" {
+		return DefaultURL
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
@@ -0,0 +1,167 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestLatestRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		permalink bool
+		token     string
+		want      string
+		wantErr   bool
+	}{
+		{name: "should resolve the latest release via permalink", permalink: true, want: "v1.2.0"},
+		{name: "should resolve the latest release via releases list", permalink: false, want: "v1.1.0"},
+		{name: "should send the token", permalink: true, token: "secret", want: "v1.2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvGitlabToken, tt.token)
+			var gotToken string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v4/projects/{project}/releases/permalink/latest", func(w http.ResponseWriter, r *http.Request) {
+				gotToken = r.Header.Get(tokenHeader)
+				if r.PathValue("project") != "group/sub/project" || !tt.permalink {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				writeJSON(w, http.StatusOK, types.GitlabRelease{TagName: "v1.2.0"})
+			})
+			mux.HandleFunc("/api/v4/projects/{project}/releases", func(w http.ResponseWriter, _ *http.Request) {
+				writeJSON(w, http.StatusOK, []types.GitlabRelease{{TagName: "v1.1.0"}, {TagName: "v1.0.0"}})
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			got, err := LatestRelease(resty.New(), server.URL, "group/sub/project", true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LatestRelease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.TagName != tt.want {
+				t.Errorf("LatestRelease() = %v, want %v", got.TagName, tt.want)
+			}
+			if gotToken != tt.token {
+				t.Errorf("token = %v, want %v", gotToken, tt.token)
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/{project}/releases/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("tag") != "v1.0.0" {
+			writeJSON(w, http.StatusNotFound, types.GitlabError{Message: "404 Not Found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, types.GitlabRelease{
+			TagName: "v1.0.0",
+			Assets: types.GitlabAssets{Links: []types.GitlabLink{
+				{ID: 1, Name: "tool-linux-amd64", URL: "https://example.com/tool-linux-amd64"},
+				{
+					ID:             2,
+					Name:           "tool-darwin-amd64",
+					URL:            "https://example.com/tool-darwin-amd64",
+					DirectAssetURL: "https://example.com/direct/tool-darwin-amd64",
+				},
+			}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	got, err := Release(resty.New(), server.URL, "group/project", "v1.0.0", true)
+	if err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	expected := []types.Asset{
+		{
+			ID:                 1,
+			Name:               "tool-linux-amd64",
+			URL:                "https://example.com/tool-linux-amd64",
+			BrowserDownloadURL: "https://example.com/tool-linux-amd64",
+		},
+		{
+			ID:                 2,
+			Name:               "tool-darwin-amd64",
+			URL:                "https://example.com/tool-darwin-amd64",
+			BrowserDownloadURL: "https://example.com/direct/tool-darwin-amd64",
+		},
+	}
+	if diff := cmp.Diff(expected, got.GetAssets()); diff != "" {
+		t.Errorf("GetAssets() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := Release(resty.New(), server.URL, "group/project", "v2.0.0", true); err == nil {
+		t.Error("Release() expected an error for an unknown release")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestAPI_WithLogger(t *testing.T) {
+	t.Setenv(EnvGitlabToken, "token")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(tokenHeader) != "token" {
+			writeJSON(w, http.StatusUnauthorized, types.GitlabError{Message: "401 Unauthorized"})
+			return
+		}
+		writeJSON(w, http.StatusOK, types.GitlabRelease{TagName: "v1.0.0"})
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	if _, err := NewAPI(server.URL).WithLogger(log.New(&logs, "", 0)).Release(resty.New(), "group/project", "v1.0.0", false); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "Using gitlab token") {
+		t.Errorf("Expected the token usage to be logged to the API logger, but got: %q", logs.String())
+	}
+}
+
+func TestSetAssetToken(t *testing.T) {
+	t.Setenv(EnvGitlabToken, "token")
+	tests := []struct {
+		name     string
+		baseURL  string
+		assetURL string
+		want     string
+	}{
+		{name: "gitlab.com asset", assetURL: "https://gitlab.com/group/project/-/releases/v1.0.0/downloads/tool", want: "token"},
+		{
+			name:     "self-hosted asset",
+			baseURL:  "https://gitlab.example.com/",
+			assetURL: "https://gitlab.example.com/api/v4/projects/1/packages/generic/tool/v1.0.0/tool",
+			want:     "token",
+		},
+		{name: "external asset", assetURL: "https://example.com/tool"},
+		{name: "other scheme", baseURL: "https://gitlab.example.com", assetURL: "http://gitlab.example.com/tool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			SetAssetToken(header, tt.baseURL, tt.assetURL)
+			if got := header.Get(tokenHeader); got != tt.want {
+				t.Errorf("SetAssetToken() header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Package google google cloud storage API functions
+package google
+
+import (
+	"errors"
+	"fmt"
+	http2 "net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/mod/semver"
+
+	"github.com/bakito/toolbox/pkg/http"
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+const stableFile = "stable.txt"
+
+var (
+	storageURL = "https://storage.googleapis.com"
+
+	// ErrNoSource is returned if the source does not define a bucket.
+	ErrNoSource = errors.New("google source must define a bucket")
+)
+
+// LatestVersion resolves the latest version of the given source.
+// The version is read from 'stable.txt' if available, otherwise the highest semver prefix is returned.
+func LatestVersion(client *resty.Client, source string) (string, error) {
+	bucket, prefix, err := parseSource(source)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.R().Get(objectURL(bucket, path.Join(prefix, stableFile)))
+	if err != nil {
+		return "", http.CheckError(err)
+	}
+	if resp.IsSuccess() {
+		return strings.TrimSpace(resp.String()), nil
+	}
+	if resp.StatusCode() != http2.StatusNotFound && resp.StatusCode() != http2.StatusForbidden {
+		return "", fmt.Errorf("google request was not successful: %s (%d)", resp.Request.URL, resp.StatusCode())
+	}
+
+	prefixes, _, err := list(client, bucket, dirPrefix(prefix), true)
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	for _, p := range prefixes {
+		v := path.Base(p)
+		sv := canonical(v)
+		if !semver.IsValid(sv) || semver.Prerelease(sv) != "" {
+			continue
+		}
+		if latest == "" || semver.Compare(sv, canonical(latest)) > 0 {
+			latest = v
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("could not find a version in %s", source)
+	}
+	return latest, nil
+}
+
+// Assets returns all objects of the given version as assets.
+// The asset name is the object path relative to the version prefix, to allow matching of OS and arch directories.
+func Assets(client *resty.Client, source, version string) ([]types.Asset, error) {
+	bucket, prefix, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	versionPrefix := dirPrefix(path.Join(prefix, version))
+	_, objects, err := list(client, bucket, versionPrefix, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []types.Asset
+	for _, o := range objects {
+		if strings.HasSuffix(o.Name, "/") {
+			continue
+		}
+		size, _ := strconv.Atoi(o.Size)
+		assets = append(assets, types.Asset{
+			Name:               strings.TrimPrefix(o.Name, versionPrefix),
+			ContentType:        o.ContentType,
+			Size:               size,
+			BrowserDownloadURL: objectURL(bucket, o.Name),
+		})
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("could not find any objects in %s", objectURL(bucket, versionPrefix))
+	}
+	return assets, nil
+}
+
+func list(client *resty.Client, bucket, prefix string, delimited bool) ([]string, []types.GoogleObject, error) {
+	var prefixes []string
+	var objects []types.GoogleObject
+	pageToken := ""
+	for {
+		gol := &types.GoogleObjects{}
+		gErr := &types.GoogleError{}
+		req := client.R().
+			SetResult(gol).
+			SetError(gErr).
+			SetHeader("Accept", "application/json").
+			SetQueryParam("prefix", prefix)
+		if delimited {
+			req.SetQueryParam("delimiter", "/")
+		}
+		if pageToken != "" {
+			req.SetQueryParam("pageToken", pageToken)
+		}
+
+		url := fmt.Sprintf("%s/storage/v1/b/%s/o", storageURL, bucket)
+		resp, err := req.Get(url)
+		if err != nil {
+			return nil, nil, http.CheckError(err)
+		}
+		if resp.IsError() {
+			return nil, nil, fmt.Errorf(
+				"google request was not successful: %s (%d) %s",
+				url,
+				resp.StatusCode(),
+				gErr.Error.Message,
+			)
+		}
+
+		prefixes = append(prefixes, gol.Prefixes...)
+		objects = append(objects, gol.Items...)
+		if gol.NextPageToken == "" {
+			return prefixes, objects, nil
+		}
+		pageToken = gol.NextPageToken
+	}
+}
+
+// parseSource splits the source into bucket and prefix.
+// Supported are '<bucket>/<prefix>', 'gs://<bucket>/<prefix>' and 'https://storage.googleapis.com/<bucket>/<prefix>'.
+func parseSource(source string) (bucket, prefix string, err error) {
+	s := strings.TrimPrefix(source, "gs://")
+	s = strings.TrimPrefix(s, storageURL+"/")
+	s = strings.Trim(s, "/")
+	bucket, prefix, _ = strings.Cut(s, "/")
+	if bucket == "" {
+		return "", "", ErrNoSource
+	}
+	return bucket, prefix, nil
+}
+
+func objectURL(bucket, name string) string {
+	return fmt.Sprintf("%s/%s/%s", storageURL, bucket, name)
+}
+
+func dirPrefix(prefix string) string {
+	if prefix == "" || prefix == "." {
+		return ""
+	}
+	return strings.TrimSuffix(prefix, "/") + "/"
+}
+
+func canonical(v string) string {
+	if strings.HasPrefix(v, "v") {
+		return v
+	}
+	return "v" + v
+}
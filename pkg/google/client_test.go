@@ -0,0 +1,113 @@
+package google
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bakito/toolbox/pkg/types"
+)
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		stable  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "should read the version from stable.txt",
+			source: "my-bucket/release",
+			stable: "v1.2.3\n",
+			want:   "v1.2.3",
+		},
+		{
+			name:   "should resolve the highest version prefix",
+			source: "gs://my-bucket/release",
+			want:   "v1.10.0",
+		},
+		{
+			name:    "should fail without bucket",
+			source:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupServer(t, tt.stable)
+
+			got, err := LatestVersion(resty.New(), tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LatestVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LatestVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssets(t *testing.T) {
+	setupServer(t, "")
+
+	got, err := Assets(resty.New(), storageURL+"/my-bucket/release", "v1.10.0")
+	if err != nil {
+		t.Fatalf("Assets() error = %v", err)
+	}
+
+	expected := []types.Asset{
+		{Name: "bin/linux/amd64/tool", Size: 42, BrowserDownloadURL: storageURL + "/my-bucket/release/v1.10.0/bin/linux/amd64/tool"},
+		{Name: "bin/linux/arm64/tool", Size: 43, BrowserDownloadURL: storageURL + "/my-bucket/release/v1.10.0/bin/linux/arm64/tool"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Assets() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func setupServer(t *testing.T, stable string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/my-bucket/release/stable.txt", func(w http.ResponseWriter, _ *http.Request) {
+		if stable == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(stable))
+	})
+	mux.HandleFunc("/storage/v1/b/my-bucket/o", func(w http.ResponseWriter, r *http.Request) {
+		var gol types.GoogleObjects
+		switch {
+		case r.URL.Query().Get("delimiter") == "/" && r.URL.Query().Get("pageToken") == "":
+			gol = types.GoogleObjects{
+				Prefixes:      []string{"release/v1.2.0/", "release/v1.9.0/"},
+				NextPageToken: "page2",
+			}
+		case r.URL.Query().Get("delimiter") == "/":
+			gol = types.GoogleObjects{Prefixes: []string{"release/v1.10.0/", "release/v1.11.0-alpha.1/", "release/latest/"}}
+		case r.URL.Query().Get("prefix") == "release/v1.10.0/":
+			gol = types.GoogleObjects{Items: []types.GoogleObject{
+				{Name: "release/v1.10.0/bin/"},
+				{Name: "release/v1.10.0/bin/linux/amd64/tool", Size: "42"},
+				{Name: "release/v1.10.0/bin/linux/arm64/tool", Size: "43"},
+			}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(gol)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	originalStorageURL := storageURL
+	storageURL = server.URL
+	t.Cleanup(func() { storageURL = originalStorageURL })
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// EnvNetrc the env variable defining the path of the netrc file.
+const EnvNetrc = "NETRC"
+
+// BasicAuth returns the value of an 'Authorization' header with the given basic auth credentials.
+func BasicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// BearerAuth returns the value of an 'Authorization' header with the given bearer token.
+func BearerAuth(token string) string {
+	return "Bearer " + token
+}
+
+// LookupNetrc returns the credentials of the host from the netrc file ($NETRC or ~/.netrc).
+// The 'default' entry is used if no machine matches the host.
+func LookupNetrc(host string) (login, password string, found bool, err error) {
+	path, err := netrcPath()
+	if err != nil {
+		return "", "", false, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	login, password, found = parseNetrc(string(b), host)
+	return login, password, found, nil
+}
+
+func netrcPath() (string, error) {
+	if p := os.Getenv(EnvNetrc); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc"), nil
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+type netrcEntry struct {
+	machine   string
+	isDefault bool
+	login     string
+	password  string
+}
+
+// parseNetrc returns the credentials of the host from the content of a netrc file.
+func parseNetrc(content, host string) (login, password string, found bool) {
+	var entries []*netrcEntry
+	var current *netrcEntry
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+			continue
+		}
+		tokens := strings.Fields(lines[i])
+		for j := 0; j < len(tokens); j++ {
+			switch tokens[j] {
+			case "machine":
+				current = &netrcEntry{}
+				entries = append(entries, current)
+				if j++; j < len(tokens) {
+					current.machine = tokens[j]
+				}
+			case "default":
+				current = &netrcEntry{isDefault: true}
+				entries = append(entries, current)
+			case "login", "password", "account":
+				key := tokens[j]
+				if j++; j < len(tokens) && current != nil {
+					switch key {
+					case "login":
+						current.login = tokens[j]
+					case "password":
+						current.password = tokens[j]
+					}
+				}
+			case "macdef":
+				// macro definitions end with an empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(tokens)
+			}
+		}
+	}
+
+	var fallback *netrcEntry
+	for _, e := range entries {
+		if e.machine == host {
+			return e.login, e.password, true
+		}
+		if e.isDefault && fallback == nil {
+			fallback = e
+		}
+	}
+	if fallback != nil {
+		return fallback.login, fallback.password, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	content := `# artifactory
+machine artifactory.example.com
+  login deployer
+  password s3cr3t
+
+macdef init
+machine ignored.example.com login macro password macro
+
+machine nexus.example.com login reader password pw account ignored
+default login anonymous password guest
+`
+	tests := []struct {
+		host         string
+		wantLogin    string
+		wantPassword string
+		wantFound    bool
+	}{
+		{host: "artifactory.example.com", wantLogin: "deployer", wantPassword: "s3cr3t", wantFound: true},
+		{host: "nexus.example.com", wantLogin: "reader", wantPassword: "pw", wantFound: true},
+		{host: "ignored.example.com", wantLogin: "anonymous", wantPassword: "guest", wantFound: true},
+		{host: "other.example.com", wantLogin: "anonymous", wantPassword: "guest", wantFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			login, password, found := parseNetrc(content, tt.host)
+			if login != tt.wantLogin || password != tt.wantPassword || found != tt.wantFound {
+				t.Errorf("parseNetrc() = %v, %v, %v, want %v, %v, %v",
+					login, password, found, tt.wantLogin, tt.wantPassword, tt.wantFound)
+			}
+		})
+	}
+
+	if _, _, found := parseNetrc("machine a.example.com login a password a", "b.example.com"); found {
+		t.Error("Expected no credentials without a matching machine or default")
+	}
+}
+
+func TestLookupNetrc(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	t.Setenv(EnvNetrc, path)
+
+	if _, _, found, err := LookupNetrc("example.com"); err != nil || found {
+		t.Fatalf("LookupNetrc() = %v, %v, want no credentials without a netrc file", found, err)
+	}
+
+	if err := os.WriteFile(path, []byte("machine example.com login user password pw\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	login, password, found, err := LookupNetrc("example.com")
+	if err != nil {
+		t.Fatalf("LookupNetrc() error = %v", err)
+	}
+	if !found || login != "user" || password != "pw" {
+		t.Errorf("LookupNetrc() = %v, %v, %v", login, password, found)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	if got := BasicAuth("Aladdin", "open sesame"); got != "Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ==" {
+		t.Errorf("BasicAuth() = %v", got)
+	}
+}
@@ -3,27 +3,77 @@ package http
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 )
 
 const (
 	dialOperation = "dial"
-	msgFormat     = "Network error - did you forget to set a proxy?\n%v"
+	// proxyConnectOperation the operation of a failed connection to the proxy
+	proxyConnectOperation = "proxyconnect"
 )
 
-var logFatalf = log.Fatalf
+// NetworkError a server could not be reached.
+type NetworkError struct {
+	URL string
+	// Proxy the proxy used for the request, empty if no proxy is configured.
+	Proxy string
+	Err   error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("network error - could not reach %s: %v (%s)", e.URL, e.Err, e.Hint())
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
 
+// Hint returns a hint about the proxy configuration.
+func (e *NetworkError) Hint() string {
+	if e.Proxy == "" {
+		return "did you forget to set a proxy? Configure it with HTTPS_PROXY / HTTP_PROXY"
+	}
+	return fmt.Sprintf("is the proxy %s reachable? Check HTTPS_PROXY / HTTP_PROXY / NO_PROXY", e.Proxy)
+}
+
+// CheckError returns a *NetworkError if the error was caused by a failed connection to the server,
+// otherwise the error is returned unchanged.
+// A *NetworkError returned by a Transport is kept, otherwise the proxy is taken from the env variables.
 func CheckError(err error) error {
-	if urlError, ok := errors.AsType[*url.Error](err); ok {
-		if opError, ok := errors.AsType[*net.OpError](urlError.Err); ok {
-			if opError.Op == dialOperation {
-				logFatalf(msgFormat, err)
-				return nil
-			}
+	return checkError(err, http.ProxyFromEnvironment)
+}
+
+func checkError(err error, proxyFunc func(*http.Request) (*url.URL, error)) error {
+	if netErr, ok := errors.AsType[*NetworkError](err); ok {
+		return netErr
+	}
+	if urlError, ok := errors.AsType[*url.Error](err); ok && isDialError(urlError.Err) {
+		u, parseErr := url.Parse(urlError.URL)
+		if parseErr != nil {
+			return &NetworkError{URL: urlError.URL, Err: err}
 		}
+		return &NetworkError{URL: urlError.URL, Proxy: proxy(proxyFunc, u), Err: err}
 	}
-
 	return err
 }
+
+// isDialError returns true if the connection to the server or the proxy failed.
+func isDialError(err error) bool {
+	opError, ok := errors.AsType[*net.OpError](err)
+	return ok && (opError.Op == dialOperation || opError.Op == proxyConnectOperation)
+}
+
+// proxy returns the redacted proxy used for the url, empty if no proxy is used.
+func proxy(proxyFunc func(*http.Request) (*url.URL, error), u *url.URL) string {
+	if proxyFunc == nil {
+		return ""
+	}
+	p, err := proxyFunc(&http.Request{URL: u})
+	if err != nil || p == nil {
+		return ""
+	}
+	return p.Redacted()
+}
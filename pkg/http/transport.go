@@ -0,0 +1,124 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Env variables overriding the network config.
+const (
+	EnvProxy              = "TOOLBOX_PROXY"
+	EnvNoProxy            = "TOOLBOX_NO_PROXY"
+	EnvCAFile             = "TOOLBOX_CA_FILE"
+	EnvInsecureSkipVerify = "TOOLBOX_INSECURE_SKIP_VERIFY"
+)
+
+// Config the proxy and TLS config of all requests.
+type Config struct {
+	// Proxy the proxy url, if empty the proxy env variables (HTTPS_PROXY, HTTP_PROXY, NO_PROXY) are used.
+	Proxy string
+	// NoProxy a comma separated list of hosts that are not accessed via the proxy.
+	// If Proxy is empty, the hosts are added to the NO_PROXY env variable.
+	NoProxy string
+	// CAFile a PEM file with additional root certificates.
+	CAFile string
+	// InsecureSkipVerify if enabled, the certificates of the servers are not verified.
+	InsecureSkipVerify bool
+}
+
+// WithEnv returns the config overridden by the TOOLBOX_* env variables.
+func (c Config) WithEnv() (Config, error) {
+	if v, ok := os.LookupEnv(EnvProxy); ok {
+		c.Proxy = v
+	}
+	if v, ok := os.LookupEnv(EnvNoProxy); ok {
+		c.NoProxy = v
+	}
+	if v, ok := os.LookupEnv(EnvCAFile); ok {
+		c.CAFile = v
+	}
+	if v, ok := os.LookupEnv(EnvInsecureSkipVerify); ok && v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value %q of %s: %w", v, EnvInsecureSkipVerify, err)
+		}
+		c.InsecureSkipVerify = insecure
+	}
+	return c, nil
+}
+
+// Transport a transport returning a *NetworkError with the proxy of the request, if a server could not be reached.
+type Transport struct {
+	*http.Transport
+}
+
+// RoundTrip executes the request, see http.Transport.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil && isDialError(err) {
+		return nil, &NetworkError{URL: req.URL.String(), Proxy: proxy(t.Proxy, req.URL), Err: err}
+	}
+	return resp, err
+}
+
+// NewTransport returns a transport applying the config.
+func NewTransport(cfg Config) (*Transport, error) {
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default transport %T", http.DefaultTransport)
+	}
+	t = t.Clone()
+
+	if cfg.Proxy != "" || cfg.NoProxy != "" {
+		pc := &httpproxy.Config{HTTPProxy: cfg.Proxy, HTTPSProxy: cfg.Proxy, NoProxy: cfg.NoProxy}
+		if cfg.Proxy == "" {
+			pc = httpproxy.FromEnvironment()
+			pc.NoProxy = strings.Trim(pc.NoProxy+","+cfg.NoProxy, ",")
+		}
+		pf := pc.ProxyFunc()
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			return pf(req.URL)
+		}
+	}
+
+	if cfg.CAFile != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pool, err := certPool(cfg.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if cfg.InsecureSkipVerify {
+			tlsConfig.InsecureSkipVerify = true //nolint:gosec // explicitly configured by the user
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+
+	return &Transport{Transport: t}, nil
+}
+
+// certPool returns the system root certificates extended by the certificates of the CA file.
+func certPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ca file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in ca file %s", caFile)
+	}
+	return pool, nil
+}
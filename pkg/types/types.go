@@ -6,13 +6,65 @@ import (
 	"strings"
 )
 
+const (
+	SourceGithub      = "github"
+	SourceGitlab      = "gitlab"
+	SourceGoogle      = "google"
+	SourceDownloadURL = "downloadURL"
+
+	// InstallModeBinary installs the binaries of the tool into the target dir (default).
+	InstallModeBinary = "binary"
+	// InstallModeTree installs the whole archive of the tool and links its binaries into the target dir.
+	InstallModeTree = "tree"
+)
+
 type Toolbox struct {
-	Tools            map[string]*Tool     `yaml:"tools,omitempty"`
-	Target           string               `yaml:"target,omitempty"`
-	Upx              bool                 `yaml:"upx,omitempty"`
-	CreateTarget     *bool                `yaml:"createTarget,omitempty"`
-	Aliases          *map[string][]string `yaml:"aliases,omitempty"`
-	ExcludedSuffixes []string             `yaml:"excludedSuffixes,omitempty"`
+	Tools              map[string]*Tool     `yaml:"tools,omitempty"`
+	Target             string               `yaml:"target,omitempty"`
+	Upx                bool                 `yaml:"upx,omitempty"`
+	CreateTarget       *bool                `yaml:"createTarget,omitempty"`
+	Aliases            *map[string][]string `yaml:"aliases,omitempty"`
+	ExcludedSuffixes   []string             `yaml:"excludedSuffixes,omitempty"`
+	GithubAPI          string               `yaml:"githubAPI,omitempty"`
+	Proxy              string               `yaml:"proxy,omitempty"`
+	NoProxy            string               `yaml:"noProxy,omitempty"`
+	CAFile             string               `yaml:"caFile,omitempty"`
+	InsecureSkipVerify bool                 `yaml:"insecureSkipVerify,omitempty"`
+	Cache              *CacheConfig         `yaml:"cache,omitempty"`
+	Download           *DownloadConfig      `yaml:"download,omitempty"`
+	Extract            *ExtractConfig       `yaml:"extract,omitempty"`
+}
+
+// DownloadConfig the config of the asset downloads.
+type DownloadConfig struct {
+	// Retries the number of retries of failed downloads (default 3).
+	Retries *int `yaml:"retries,omitempty"`
+	// Timeout the maximum duration of a single download attempt (e.g. '5m', default 10m).
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// ExtractConfig the limits of the extraction of archives, a negative value disables a limit.
+type ExtractConfig struct {
+	// MaxSizeMB the maximum size of all files extracted from an archive in MiB (default 4096).
+	MaxSizeMB int64 `yaml:"maxSizeMB,omitempty"`
+	// MaxEntrySizeMB the maximum size of a single extracted file in MiB (default 2048).
+	MaxEntrySizeMB int64 `yaml:"maxEntrySizeMB,omitempty"`
+	// MaxEntries the maximum number of entries extracted from an archive (default 100000).
+	MaxEntries int `yaml:"maxEntries,omitempty"`
+}
+
+// CacheConfig the config of the download cache.
+type CacheConfig struct {
+	// Disabled if enabled, no downloads are cached.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// MaxAge entries not used within this duration are evicted (e.g. '168h', default 30 days).
+	MaxAge string `yaml:"maxAge,omitempty"`
+	// MaxSizeMB the maximum size of the cache in MiB (default 1024).
+	MaxSizeMB int64 `yaml:"maxSizeMB,omitempty"`
+	// APIDisabled if enabled, no github API responses are cached.
+	APIDisabled bool `yaml:"apiDisabled,omitempty"`
+	// APITTL the duration cached github API responses are used without revalidation (default '10m').
+	APITTL string `yaml:"apiTTL,omitempty"`
 }
 
 func (t *Toolbox) GetTools() []*Tool {
@@ -20,7 +72,7 @@ func (t *Toolbox) GetTools() []*Tool {
 	for n := range t.Tools {
 		tool := t.Tools[n]
 
-		if tool.Github != "" || tool.DownloadURL != "" || tool.Google != "" {
+		if tool.Github != "" || tool.DownloadURL != "" || tool.Google != "" || tool.Gitlab != "" {
 			if tool.Name == "" {
 				tool.Name = n
 			}
@@ -65,19 +117,75 @@ func (t *Toolbox) HasGithubTools() bool {
 	return false
 }
 
+func (t *Toolbox) HasGitlabTools() bool {
+	for _, tool := range t.Tools {
+		if tool.Gitlab != "" {
+			return true
+		}
+	}
+	return false
+}
+
 type Tool struct {
 	Name            string   `yaml:"name,omitempty"`
 	Github          string   `yaml:"github,omitempty"`
+	GithubAPI       string   `yaml:"githubAPI,omitempty"`
+	Gitlab          string   `yaml:"gitlab,omitempty"`
+	GitlabURL       string   `yaml:"gitlabURL,omitempty"`
 	Google          string   `yaml:"google,omitempty"`
 	DownloadURL     string   `yaml:"downloadURL,omitempty"`
 	Version         string   `yaml:"version,omitempty"`
 	Additional      []string `yaml:"additional,omitempty"`
 	Check           string   `yaml:"check,omitempty"`
+	Checksum        string   `yaml:"checksum,omitempty"`
 	SkipUpx         bool     `yaml:"skipUpx,omitempty"`
+	Auth            *Auth    `yaml:"auth,omitempty"`
+	InstallMode     string   `yaml:"installMode,omitempty"`
 	CouldNotBeFound bool     `yaml:"-"`
 	Invalid         bool     `yaml:"-"`
 }
 
+// Auth the credentials of the requests of a downloadURL tool.
+// The credentials are never part of the config, they are read from env variables or the netrc file.
+type Auth struct {
+	// BearerTokenEnv the env variable holding a bearer token.
+	BearerTokenEnv string `yaml:"bearerTokenEnv,omitempty"`
+	// UsernameEnv the env variable holding the basic auth username.
+	UsernameEnv string `yaml:"usernameEnv,omitempty"`
+	// PasswordEnv the env variable holding the basic auth password.
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+	// Netrc if enabled, the basic auth credentials of the host are read from the netrc file ($NETRC or ~/.netrc).
+	Netrc bool `yaml:"netrc,omitempty"`
+}
+
+// Source returns the source the tool is downloaded from.
+func (t *Tool) Source() string {
+	switch {
+	case t.DownloadURL != "":
+		return SourceDownloadURL
+	case t.Github != "":
+		return SourceGithub
+	case t.Gitlab != "":
+		return SourceGitlab
+	case t.Google != "":
+		return SourceGoogle
+	}
+	return ""
+}
+
+// TreeInstall returns true if the whole archive of the tool is installed.
+func (t *Tool) TreeInstall() bool {
+	return t.InstallMode == InstallModeTree
+}
+
+// PinnedVersion returns the configured version, if the version is not resolved via URL.
+func (t *Tool) PinnedVersion() string {
+	if strings.HasPrefix(t.Version, "http") {
+		return ""
+	}
+	return t.Version
+}
+
 type ToolVersion struct {
 	Name    string `yaml:"name"`
 	Version string `yaml:"version"`
@@ -86,3 +194,21 @@ type ToolVersion struct {
 type Versions struct {
 	Versions map[string]string `yaml:"versions"`
 }
+
+// ToolStatus the configured and installed state of a tool.
+type ToolStatus struct {
+	Name      string `json:"name"                yaml:"name"`
+	Source    string `json:"source"              yaml:"source"`
+	Pinned    string `json:"pinned,omitempty"    yaml:"pinned,omitempty"`
+	Installed string `json:"installed,omitempty" yaml:"installed,omitempty"`
+	Latest    string `json:"latest,omitempty"    yaml:"latest,omitempty"`
+	Exists    bool   `json:"exists"              yaml:"exists"`
+}
+
+// Manifest the files installed by toolbox into the target dir.
+type Manifest struct {
+	// Platform the platform '<os>/<arch>' the tools were fetched for.
+	Platform string `yaml:"platform,omitempty"`
+	// Files maps the installed file names to the name of the tool they belong to.
+	Files map[string]string `yaml:"files"`
+}
@@ -0,0 +1,20 @@
+package types
+
+// BundleIndex the index of a tool bundle.
+type BundleIndex struct {
+	Tools map[string]*BundledTool `yaml:"tools"`
+}
+
+// BundledTool the version of a bundled tool and its binaries per platform ('<os>/<arch>').
+type BundledTool struct {
+	Version   string                   `yaml:"version"`
+	Platforms map[string][]BundledFile `yaml:"platforms"`
+}
+
+// BundledFile a binary contained in the bundle.
+type BundledFile struct {
+	Name string `yaml:"name"`
+	// Path the path of the binary relative to the bundle root.
+	Path   string `yaml:"path"`
+	Sha256 string `yaml:"sha256"`
+}
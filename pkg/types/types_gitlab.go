@@ -0,0 +1,51 @@
+package types
+
+type GitlabError struct {
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// GetMessage returns the error message.
+func (e *GitlabError) GetMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Error
+}
+
+type GitlabRelease struct {
+	TagName     string       `json:"tag_name"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Assets      GitlabAssets `json:"assets"`
+}
+
+type GitlabAssets struct {
+	Links []GitlabLink `json:"links"`
+}
+
+type GitlabLink struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+	LinkType       string `json:"link_type"`
+}
+
+// GetAssets returns the release links as assets.
+func (r *GitlabRelease) GetAssets() []Asset {
+	var assets []Asset
+	for _, l := range r.Assets.Links {
+		url := l.DirectAssetURL
+		if url == "" {
+			url = l.URL
+		}
+		assets = append(assets, Asset{
+			ID:                 l.ID,
+			Name:               l.Name,
+			URL:                l.URL,
+			BrowserDownloadURL: url,
+		})
+	}
+	return assets
+}
@@ -0,0 +1,22 @@
+package types
+
+// GoogleObjects a page of the google cloud storage objects list response.
+type GoogleObjects struct {
+	Prefixes      []string       `json:"prefixes"`
+	Items         []GoogleObject `json:"items"`
+	NextPageToken string         `json:"nextPageToken"`
+}
+
+type GoogleObject struct {
+	Name        string `json:"name"`
+	Size        string `json:"size"`
+	ContentType string `json:"contentType"`
+	MediaLink   string `json:"mediaLink"`
+}
+
+type GoogleError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
@@ -0,0 +1,21 @@
+package types
+
+// Lock the content of the toolbox lock file.
+type Lock struct {
+	Tools map[string]*LockedTool `yaml:"tools"`
+}
+
+// LockedTool the resolved version of a tool and the downloaded assets per platform ('<os>/<arch>').
+type LockedTool struct {
+	Version   string                   `yaml:"version"`
+	Platforms map[string][]LockedAsset `yaml:"platforms"`
+}
+
+// LockedAsset a downloaded asset of a tool.
+type LockedAsset struct {
+	Name   string `yaml:"name"`
+	Asset  string `yaml:"asset"`
+	URL    string `yaml:"url"`
+	Size   int64  `yaml:"size"`
+	Sha256 string `yaml:"sha256"`
+}
@@ -27,9 +27,10 @@ func TestToolbox_GetTools(t *testing.T) {
 					"abc":       {Name: "abc", Google: "bar"},
 					"no-source": {Name: "no-source"},
 					"foo":       {DownloadURL: "url"},
+					"lab":       {Gitlab: "group/project"},
 				},
 			},
-			want: []string{"abc", "foo", "xyz"},
+			want: []string{"abc", "foo", "lab", "xyz"},
 		},
 	}
 